@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Адаптивное кодирование Хаффмана (алгоритм FGK)
+//
+// Кодер и декодер начинают с дерева из одного листа NYT ("ещё не встречался")
+// и после каждого символа одинаково перестраивают его, поэтому таблицу кодов
+// передавать не нужно. Новый символ кодируется путём до NYT и литералом
+// (см. escape.go). Дерево поддерживает свойство братства: узлы упорядочены по номерам
+// так, что их веса не возрастают, а братья стоят рядом.
+type adaptiveNode struct {
+	weight int
+	symbol string
+	leaf   bool
+	parent *adaptiveNode
+	left   *adaptiveNode
+	right  *adaptiveNode
+	order  int // позиция в adaptiveHuffman.nodes, у корня 0
+}
+
+type adaptiveHuffman struct {
+	nodes  []*adaptiveNode // по убыванию номера узла: корень первый, NYT последний
+	leaves map[string]*adaptiveNode
+	root   *adaptiveNode
+	nyt    *adaptiveNode
+}
+
+func newAdaptiveHuffman() *adaptiveHuffman {
+	root := &adaptiveNode{leaf: true}
+	return &adaptiveHuffman{
+		nodes:  []*adaptiveNode{root},
+		leaves: make(map[string]*adaptiveNode),
+		root:   root,
+		nyt:    root,
+	}
+}
+
+// записывает путь от корня до узла
+func (t *adaptiveHuffman) writePath(bw *bitWriter, node *adaptiveNode) {
+	var path []uint
+	for ; node.parent != nil; node = node.parent {
+		if node.parent.left == node {
+			path = append(path, 0)
+		} else {
+			path = append(path, 1)
+		}
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		bw.writeBit(path[i])
+	}
+}
+
+func (t *adaptiveHuffman) encode(bw *bitWriter, symbol string) error {
+	if leaf, exists := t.leaves[symbol]; exists {
+		t.writePath(bw, leaf)
+	} else {
+		t.writePath(bw, t.nyt)
+		if err := writeLiteral(bw, symbol); err != nil {
+			return err
+		}
+	}
+	t.update(symbol)
+	return nil
+}
+
+func (t *adaptiveHuffman) decode(br *bitReader) (string, error) {
+	node := t.root
+	for !node.leaf {
+		bit, err := br.readBit()
+		if err != nil {
+			return "", err
+		}
+		if bit == 0 {
+			node = node.left
+		} else {
+			node = node.right
+		}
+	}
+
+	symbol := node.symbol
+	if node == t.nyt {
+		literal, err := readPackedLiteral(br)
+		if err != nil {
+			return "", err
+		}
+		symbol = literal
+	}
+	t.update(symbol)
+	return symbol, nil
+}
+
+func (t *adaptiveHuffman) update(symbol string) {
+	q, exists := t.leaves[symbol]
+	if !exists {
+		// старый NYT становится внутренним узлом с новым NYT слева и новым листом справа
+		parent := t.nyt
+		parent.leaf = false
+		leaf := &adaptiveNode{leaf: true, symbol: symbol, parent: parent, order: len(t.nodes)}
+		nyt := &adaptiveNode{leaf: true, parent: parent, order: len(t.nodes) + 1}
+		parent.left, parent.right = nyt, leaf
+		t.nodes = append(t.nodes, leaf, nyt)
+		t.leaves[symbol] = leaf
+		t.nyt = nyt
+		q = leaf
+	}
+
+	for ; q != nil; q = q.parent {
+		// старший по номеру узел в блоке узлов того же веса
+		leader := q
+		for leader.order > 0 && t.nodes[leader.order-1].weight == q.weight {
+			leader = t.nodes[leader.order-1]
+		}
+		if leader != q && leader != q.parent {
+			t.swap(q, leader)
+		}
+		q.weight++
+	}
+}
+
+// меняет местами два поддерева вместе с их номерами
+func (t *adaptiveHuffman) swap(a, b *adaptiveNode) {
+	ap, bp := a.parent, b.parent
+	if ap == bp {
+		ap.left, ap.right = ap.right, ap.left
+	} else {
+		if ap.left == a {
+			ap.left = b
+		} else {
+			ap.right = b
+		}
+		if bp.left == b {
+			bp.left = a
+		} else {
+			bp.right = a
+		}
+		a.parent, b.parent = bp, ap
+	}
+	t.nodes[a.order], t.nodes[b.order] = b, a
+	a.order, b.order = b.order, a.order
+}
+
+// кодирует текст за один проход, возвращает упакованные биты и их точное количество
+func adaptiveHuffmanEncode(text string) ([]byte, uint64, error) {
+	var buf bytes.Buffer
+	bw := newBitWriter(&buf)
+	t := newAdaptiveHuffman()
+	for _, symbol := range textSymbols(text) {
+		if err := t.encode(bw, symbol); err != nil {
+			return nil, 0, err
+		}
+	}
+	bitCount := bw.count
+	if err := bw.flush(); err != nil {
+		return nil, 0, err
+	}
+	return buf.Bytes(), bitCount, nil
+}
+
+func adaptiveHuffmanDecode(data []byte, count int) (string, error) {
+	br := newBitReader(bytes.NewReader(data))
+	t := newAdaptiveHuffman()
+	var decoded strings.Builder
+	for n := 0; n < count; n++ {
+		symbol, err := t.decode(br)
+		if err != nil {
+			return "", err
+		}
+		decoded.WriteString(symbol)
+	}
+	return decoded.String(), nil
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/bits"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Асимметричные системы счисления (ANS)
+//
+// Всё состояние кодера — одно целое число x. Кодирование символа s с частотой f_s
+// из суммы M = 2^ansScaleBits переводит x примерно в x*M/f_s, то есть добавляет
+// -log2(f_s/M) бит — почти ровно столько, сколько требует энтропия.
+// Декодер работает в обратном порядке, поэтому кодер проходит текст с конца.
+const (
+	ansScaleBits = 12
+	ansTotal     = 1 << ansScaleBits
+	// нижняя граница состояния rANS: состояние держится в [rANSLow, rANSLow*256)
+	rANSLow = uint32(1) << 23
+)
+
+// частоты, приведённые к сумме ansTotal, и их накопленные суммы
+type ansModel struct {
+	symbols []string
+	index   map[string]int
+	freq    []uint32
+	cum     []uint32
+	slot    []uint16 // slot[i] — символ, которому принадлежит накопленная частота i
+}
+
+// Квантование частот к сумме 2^scaleBits
+//
+// Каждый встреченный символ получает хотя бы единицу, а округлённая разница
+// с нужной суммой снимается с самых частых символов (alphabet отсортирован по убыванию).
+// У пустого алфавита частот нет.
+func quantizeFrequencies(alphabet []Symbol, scaleBits int) []uint32 {
+	if len(alphabet) == 0 {
+		return nil
+	}
+	target := uint32(1) << scaleBits
+	total := 0
+	for _, s := range alphabet {
+		total += s.Count
+	}
+
+	freq := make([]uint32, len(alphabet))
+	sum := uint32(0)
+	for i, s := range alphabet {
+		f := uint32(float64(s.Count) * float64(target) / float64(total))
+		if f == 0 {
+			f = 1
+		}
+		freq[i] = f
+		sum += f
+	}
+
+	for i := 0; sum != target; i = (i + 1) % len(freq) {
+		if sum < target {
+			freq[i]++
+			sum++
+		} else if freq[i] > 1 {
+			freq[i]--
+			sum--
+		}
+	}
+	return freq
+}
+
+func newANSModel(alphabet []Symbol) (*ansModel, error) {
+	if len(alphabet) > ansTotal {
+		return nil, fmt.Errorf("ANS поддерживает не больше %d символов, а в алфавите %d", ansTotal, len(alphabet))
+	}
+	m := &ansModel{
+		symbols: make([]string, len(alphabet)),
+		index:   make(map[string]int, len(alphabet)),
+		freq:    quantizeFrequencies(alphabet, ansScaleBits),
+		cum:     make([]uint32, len(alphabet)+1),
+		slot:    make([]uint16, ansTotal),
+	}
+	for i, s := range alphabet {
+		m.symbols[i] = s.Char
+		m.index[s.Char] = i
+		m.cum[i+1] = m.cum[i] + m.freq[i]
+		for j := m.cum[i]; j < m.cum[i+1]; j++ {
+			m.slot[j] = uint16(i)
+		}
+	}
+	return m, nil
+}
+
+// переводит текст в номера символов модели
+func (m *ansModel) indices(text string) ([]int, error) {
+	result := make([]int, 0, len(text))
+	for _, symbol := range textSymbols(text) {
+		i, exists := m.index[symbol]
+		if !exists {
+			return nil, fmt.Errorf("символа %q нет в алфавите", symbol)
+		}
+		result = append(result, i)
+	}
+	return result, nil
+}
+
+// у пустого алфавита (пустой текст) декодировать нечего
+func (m *ansModel) checkCount(count int) error {
+	if count > 0 && len(m.symbols) == 0 {
+		return fmt.Errorf("алфавит пуст, а в тексте %d символов", count)
+	}
+	return nil
+}
+
+// rANS с побайтовой перенормировкой
+func rANSEncode(text string, alphabet []Symbol) ([]byte, error) {
+	m, err := newANSModel(alphabet)
+	if err != nil {
+		return nil, err
+	}
+	symbols, err := m.indices(text)
+	if err != nil {
+		return nil, err
+	}
+
+	// байты выводятся в обратном порядке и в конце разворачиваются
+	out := make([]byte, 0, len(text)/2)
+	x := rANSLow
+	for i := len(symbols) - 1; i >= 0; i-- {
+		s := symbols[i]
+		freq := m.freq[s]
+		xMax := ((rANSLow >> ansScaleBits) << 8) * freq
+		for x >= xMax {
+			out = append(out, byte(x))
+			x >>= 8
+		}
+		x = (x/freq)<<ansScaleBits + x%freq + m.cum[s]
+	}
+	out = append(out, byte(x), byte(x>>8), byte(x>>16), byte(x>>24))
+
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out, nil
+}
+
+func rANSDecode(data []byte, alphabet []Symbol, count int) (string, error) {
+	m, err := newANSModel(alphabet)
+	if err != nil {
+		return "", err
+	}
+	if err := m.checkCount(count); err != nil {
+		return "", err
+	}
+	if len(data) < 4 {
+		return "", fmt.Errorf("поток rANS короче начального состояния")
+	}
+
+	x := uint32(data[0])<<24 | uint32(data[1])<<16 | uint32(data[2])<<8 | uint32(data[3])
+	pos := 4
+	var decoded strings.Builder
+	for n := 0; n < count; n++ {
+		slot := x & (ansTotal - 1)
+		s := m.slot[slot]
+		decoded.WriteString(m.symbols[s])
+		x = m.freq[s]*(x>>ansScaleBits) + slot - m.cum[s]
+		for x < rANSLow {
+			if pos >= len(data) {
+				return "", errUnexpectedEnd
+			}
+			x = x<<8 | uint32(data[pos])
+			pos++
+		}
+	}
+	return decoded.String(), nil
+}
+
+// Таблица tANS
+//
+// Состояния x лежат в [L, 2L), L = ansTotal. Символы раскладываются по ячейкам
+// таблицы с нечётным шагом (как в FSE), каждый символ занимает freq ячеек.
+type tANSTable struct {
+	model *ansModel
+	// для декодера: символ ячейки, число читаемых бит и база следующего состояния
+	symbol  []uint16
+	nbBits  []uint8
+	newBase []uint16
+	// для кодера: encode[cum[s]+k] — состояние L+i k-й по счёту ячейки символа s
+	encode []uint16
+}
+
+func newTANSTable(alphabet []Symbol) (*tANSTable, error) {
+	m, err := newANSModel(alphabet)
+	if err != nil {
+		return nil, err
+	}
+	t := &tANSTable{
+		model:   m,
+		symbol:  make([]uint16, ansTotal),
+		nbBits:  make([]uint8, ansTotal),
+		newBase: make([]uint16, ansTotal),
+		encode:  make([]uint16, ansTotal),
+	}
+	if len(m.symbols) == 0 {
+		return t, nil
+	}
+
+	step := ansTotal>>1 + ansTotal>>3 + 3
+	pos := 0
+	for s := range m.symbols {
+		for j := uint32(0); j < m.freq[s]; j++ {
+			t.symbol[pos] = uint16(s)
+			pos = (pos + step) & (ansTotal - 1)
+		}
+	}
+
+	next := make([]uint32, len(m.symbols))
+	copy(next, m.freq)
+	for i := 0; i < ansTotal; i++ {
+		s := t.symbol[i]
+		xs := next[s]
+		next[s]++
+		nb := ansScaleBits - (bits.Len32(xs) - 1)
+		t.nbBits[i] = uint8(nb)
+		t.newBase[i] = uint16(xs<<uint(nb) - ansTotal)
+		t.encode[m.cum[s]+xs-m.freq[s]] = uint16(ansTotal + i)
+	}
+	return t, nil
+}
+
+// tANS: кодер выдаёт младшие биты состояния, декодер читает их в обратном порядке
+func tANSEncode(text string, alphabet []Symbol) ([]byte, uint64, error) {
+	t, err := newTANSTable(alphabet)
+	if err != nil {
+		return nil, 0, err
+	}
+	symbols, err := t.model.indices(text)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	type chunk struct {
+		value uint16
+		n     uint8
+	}
+	chunks := make([]chunk, len(symbols))
+	x := uint32(ansTotal)
+	for i := len(symbols) - 1; i >= 0; i-- {
+		s := symbols[i]
+		freq := t.model.freq[s]
+		n := uint8(0)
+		for x>>n >= 2*freq {
+			n++
+		}
+		chunks[i] = chunk{uint16(x & (1<<n - 1)), n}
+		x = uint32(t.encode[t.model.cum[s]+x>>n-freq])
+	}
+
+	var buf bytes.Buffer
+	bw := newBitWriter(&buf)
+	bw.writeBits(uint64(x-ansTotal), ansScaleBits)
+	for _, c := range chunks {
+		bw.writeBits(uint64(c.value), int(c.n))
+	}
+	bitCount := bw.count
+	if err := bw.flush(); err != nil {
+		return nil, 0, err
+	}
+	return buf.Bytes(), bitCount, nil
+}
+
+func tANSDecode(data []byte, alphabet []Symbol, count int) (string, error) {
+	t, err := newTANSTable(alphabet)
+	if err != nil {
+		return "", err
+	}
+	if err := t.model.checkCount(count); err != nil {
+		return "", err
+	}
+	br := newBitReader(bytes.NewReader(data))
+	state, err := br.readBits(ansScaleBits)
+	if err != nil {
+		return "", err
+	}
+
+	var decoded strings.Builder
+	for n := 0; n < count; n++ {
+		decoded.WriteString(t.model.symbols[t.symbol[state]])
+		low, err := br.readBits(int(t.nbBits[state]))
+		if err != nil {
+			return "", err
+		}
+		state = uint64(t.newBase[state]) + low
+	}
+	return decoded.String(), nil
+}
+
+// результат одного кодера: размер и скорость кодирования/декодирования
+type coderBenchmark struct {
+	Name         string
+	Size         int
+	EncodeMBps   float64
+	DecodeMBps   float64
+	RoundTripped bool
+}
+
+// сравнивает rANS и tANS с путём Хаффмана (bitWriter + дерево декодирования)
+func compareANSWithHuffman(text string, alphabet []Symbol, huffmanCodes map[string]string) ([]coderBenchmark, error) {
+	textLength := utf8.RuneCountInString(text)
+	mbps := func(d time.Duration) float64 {
+		return float64(len(text)) / d.Seconds() / 1e6
+	}
+
+	var results []coderBenchmark
+
+	start := time.Now()
+	var buf bytes.Buffer
+	bw := newBitWriter(&buf)
+	if err := writeEncodedText(bw, text, huffmanCodes); err != nil {
+		return nil, err
+	}
+	huffmanBits := bw.count
+	if err := bw.flush(); err != nil {
+		return nil, err
+	}
+	encodeTime := time.Since(start)
+	root, err := buildDecodeTree(huffmanCodes)
+	if err != nil {
+		return nil, err
+	}
+	start = time.Now()
+	var huffmanDecoded strings.Builder
+	if err := readEncodedText(newBitReader(bytes.NewReader(buf.Bytes())), root, huffmanBits, &huffmanDecoded); err != nil {
+		return nil, err
+	}
+	results = append(results, coderBenchmark{"Хаффман", buf.Len(), mbps(encodeTime), mbps(time.Since(start)), huffmanDecoded.String() == text})
+
+	start = time.Now()
+	rANSData, err := rANSEncode(text, alphabet)
+	if err != nil {
+		return nil, err
+	}
+	encodeTime = time.Since(start)
+	start = time.Now()
+	decoded, err := rANSDecode(rANSData, alphabet, textLength)
+	if err != nil {
+		return nil, err
+	}
+	results = append(results, coderBenchmark{"rANS", len(rANSData), mbps(encodeTime), mbps(time.Since(start)), decoded == text})
+
+	start = time.Now()
+	tANSData, _, err := tANSEncode(text, alphabet)
+	if err != nil {
+		return nil, err
+	}
+	encodeTime = time.Since(start)
+	start = time.Now()
+	decoded, err = tANSDecode(tANSData, alphabet, textLength)
+	if err != nil {
+		return nil, err
+	}
+	results = append(results, coderBenchmark{"tANS", len(tANSData), mbps(encodeTime), mbps(time.Since(start)), decoded == text})
+
+	return results, nil
+}
+
+func printCoderBenchmarks(w io.Writer, results []coderBenchmark) {
+	fmt.Fprintf(w, "  %-10s %12s %14s %14s\n", "Кодер", "Байт", "Кодир., МБ/с", "Декодир., МБ/с")
+	for _, r := range results {
+		mark := "✓"
+		if !r.RoundTripped {
+			mark = "✗"
+		}
+		fmt.Fprintf(w, "  %-10s %12d %14.1f %14.1f %s\n", r.Name, r.Size, r.EncodeMBps, r.DecodeMBps, mark)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// текст из n разных символов, первый из которых повторяется repeat раз
+func distinctSymbolsText(n, repeat int) string {
+	var b strings.Builder
+	b.WriteString(strings.Repeat("a", repeat))
+	for i := 1; i < n; i++ {
+		b.WriteRune(rune(0x4E00 + i))
+	}
+	return b.String()
+}
+
+func ansRoundTripInputs() map[string]string {
+	inputs := roundTripInputs()
+	inputs["алфавит на всю таблицу"] = distinctSymbolsText(ansTotal, 1)
+	inputs["алфавит на всю таблицу с частым символом"] = distinctSymbolsText(ansTotal, 10000)
+	inputs["алфавит чуть меньше таблицы"] = distinctSymbolsText(ansTotal-1, 3)
+	return inputs
+}
+
+func TestQuantizeFrequencies(t *testing.T) {
+	for name, input := range ansRoundTripInputs() {
+		alphabet := makeAlphabet(input)
+		freq := quantizeFrequencies(alphabet, ansScaleBits)
+		if len(freq) != len(alphabet) {
+			t.Fatalf("%s: %d частот на %d символов", name, len(freq), len(alphabet))
+		}
+		if len(alphabet) == 0 {
+			continue
+		}
+		sum := uint32(0)
+		for i, f := range freq {
+			if f == 0 {
+				t.Errorf("%s: символ %q получил нулевую частоту", name, alphabet[i].Char)
+			}
+			sum += f
+		}
+		if sum != ansTotal {
+			t.Errorf("%s: сумма частот %d, ожидалось %d", name, sum, ansTotal)
+		}
+	}
+}
+
+func TestANSRoundTrip(t *testing.T) {
+	for name, input := range ansRoundTripInputs() {
+		alphabet := makeAlphabet(input)
+		count := utf8.RuneCountInString(input)
+
+		data, err := rANSEncode(input, alphabet)
+		if err != nil {
+			t.Errorf("%s, rANS: %v", name, err)
+		} else if decoded, err := rANSDecode(data, alphabet, count); err != nil {
+			t.Errorf("%s, rANS: %v", name, err)
+		} else if decoded != input {
+			t.Errorf("%s, rANS: текст не восстановлен", name)
+		}
+
+		data, _, err = tANSEncode(input, alphabet)
+		if err != nil {
+			t.Errorf("%s, tANS: %v", name, err)
+		} else if decoded, err := tANSDecode(data, alphabet, count); err != nil {
+			t.Errorf("%s, tANS: %v", name, err)
+		} else if decoded != input {
+			t.Errorf("%s, tANS: текст не восстановлен", name)
+		}
+	}
+}
+
+func TestANSErrors(t *testing.T) {
+	tooMany := makeAlphabet(distinctSymbolsText(ansTotal+1, 1))
+	if _, err := rANSEncode("a", tooMany); err == nil {
+		t.Errorf("rANS: %d символов больше таблицы, ожидалась ошибка", len(tooMany))
+	}
+	if _, _, err := tANSEncode("a", tooMany); err == nil {
+		t.Errorf("tANS: %d символов больше таблицы, ожидалась ошибка", len(tooMany))
+	}
+	if _, err := rANSDecode([]byte{0, 0x80, 0, 0}, nil, 1); err == nil {
+		t.Error("rANS: символ из пустого алфавита, ожидалась ошибка")
+	}
+	if _, err := tANSDecode([]byte{0, 0}, nil, 1); err == nil {
+		t.Error("tANS: символ из пустого алфавита, ожидалась ошибка")
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// Арифметическое кодирование с целочисленными границами фиксированной точности
+//
+// Интервал [low, high] сужается пропорционально частоте каждого символа.
+// Когда старшие биты границ совпадают, они выводятся в поток. Если интервал
+// зажат вокруг середины (low >= 1/4, high < 3/4), он растягивается, а решение
+// об очередном бите откладывается (pending) до тех пор, пока не станет ясно,
+// в какую половину попал интервал, — так исключается потеря точности.
+const (
+	arithPrecision = 32
+	arithFull      = uint64(1)<<arithPrecision - 1
+	arithHalf      = uint64(1) << (arithPrecision - 1)
+	arithQuarter   = uint64(1) << (arithPrecision - 2)
+	// сумма частот должна быть меньше четверти диапазона, иначе интервал может схлопнуться
+	arithMaxTotal = arithQuarter - 1
+)
+
+// накопленные частоты символов в порядке алфавита
+type frequencyModel struct {
+	symbols []string
+	index   map[string]int
+	cum     []uint64 // cum[i] — сумма частот символов 0..i-1, cum[len] = total
+	total   uint64
+}
+
+func newFrequencyModel(alphabet []Symbol) *frequencyModel {
+	total := uint64(0)
+	for _, s := range alphabet {
+		total += uint64(s.Count)
+	}
+	// при слишком большой сумме частоты пропорционально уменьшаются, но не обнуляются
+	scale := 1.0
+	if total > arithMaxTotal {
+		scale = float64(arithMaxTotal-uint64(len(alphabet))) / float64(total)
+	}
+
+	m := &frequencyModel{
+		symbols: make([]string, len(alphabet)),
+		index:   make(map[string]int, len(alphabet)),
+		cum:     make([]uint64, len(alphabet)+1),
+	}
+	for i, s := range alphabet {
+		count := uint64(float64(s.Count) * scale)
+		if count == 0 {
+			count = 1
+		}
+		m.symbols[i] = s.Char
+		m.index[s.Char] = i
+		m.cum[i+1] = m.cum[i] + count
+	}
+	m.total = m.cum[len(alphabet)]
+	return m
+}
+
+// символ, в диапазон которого попадает накопленная частота value
+func (m *frequencyModel) find(value uint64) int {
+	return sort.Search(len(m.symbols), func(i int) bool { return m.cum[i+1] > value })
+}
+
+// кодирует текст посимвольно, возвращает упакованные биты и их точное количество
+func arithmeticEncode(text string, alphabet []Symbol) ([]byte, uint64, error) {
+	m := newFrequencyModel(alphabet)
+	var buf bytes.Buffer
+	bw := newBitWriter(&buf)
+
+	low, high := uint64(0), arithFull
+	pending := 0
+	emit := func(bit uint) {
+		bw.writeBit(bit)
+		for ; pending > 0; pending-- {
+			bw.writeBit(1 - bit)
+		}
+	}
+
+	for _, symbol := range textSymbols(text) {
+		i, exists := m.index[symbol]
+		if !exists {
+			return nil, 0, fmt.Errorf("символа %q нет в алфавите", symbol)
+		}
+		rng := high - low + 1
+		high = low + rng*m.cum[i+1]/m.total - 1
+		low = low + rng*m.cum[i]/m.total
+
+	renormalize:
+		for {
+			switch {
+			case high < arithHalf:
+				emit(0)
+			case low >= arithHalf:
+				emit(1)
+				low -= arithHalf
+				high -= arithHalf
+			case low >= arithQuarter && high < arithHalf+arithQuarter:
+				pending++
+				low -= arithQuarter
+				high -= arithQuarter
+			default:
+				break renormalize
+			}
+			low = low << 1
+			high = high<<1 | 1
+		}
+	}
+
+	// двух бит (с отложенными) достаточно, чтобы указать точку внутри последнего интервала
+	pending++
+	if low < arithQuarter {
+		emit(0)
+	} else {
+		emit(1)
+	}
+	bits := bw.count
+	if err := bw.flush(); err != nil {
+		return nil, 0, err
+	}
+	return buf.Bytes(), bits, nil
+}
+
+// декодирует count символов; за концом потока считается, что идут нули
+func arithmeticDecode(data []byte, alphabet []Symbol, count int) (string, error) {
+	m := newFrequencyModel(alphabet)
+	br := newBitReader(bytes.NewReader(data))
+	nextBit := func() uint64 {
+		bit, err := br.readBit()
+		if err != nil {
+			return 0
+		}
+		return uint64(bit)
+	}
+
+	low, high := uint64(0), arithFull
+	value := uint64(0)
+	for i := 0; i < arithPrecision; i++ {
+		value = value<<1 | nextBit()
+	}
+
+	var decoded bytes.Buffer
+	for n := 0; n < count; n++ {
+		rng := high - low + 1
+		scaled := ((value-low+1)*m.total - 1) / rng
+		i := m.find(scaled)
+		if i >= len(m.symbols) {
+			return "", fmt.Errorf("повреждённый поток на символе %d", n)
+		}
+		decoded.WriteString(m.symbols[i])
+
+		high = low + rng*m.cum[i+1]/m.total - 1
+		low = low + rng*m.cum[i]/m.total
+	renormalize:
+		for {
+			switch {
+			case high < arithHalf:
+			case low >= arithHalf:
+				low -= arithHalf
+				high -= arithHalf
+				value -= arithHalf
+			case low >= arithQuarter && high < arithHalf+arithQuarter:
+				low -= arithQuarter
+				high -= arithQuarter
+				value -= arithQuarter
+			default:
+				break renormalize
+			}
+			low = low << 1
+			high = high<<1 | 1
+			value = value<<1 | nextBit()
+		}
+	}
+	return decoded.String(), nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Служебный символ выхода биграмм: за его кодом следует код из таблицы одиночных
+// символов. Ни один настоящий символ или биграмма не бывает пустой строкой,
+// поэтому пустая строка не пересекается с алфавитом.
+const escapeSymbol = ""
+
+// Кодирование биграммами
+//
+// Текст разбивается на неперекрывающиеся пары символов. Если пары нет в таблице
+// или в конце текста остался одиночный символ, выводится код escapeSymbol,
+// за ним код одного символа из таблицы одиночных символов, и разбор продолжается
+// со следующего символа. Если нет кода выхода или кода одиночного символа,
+// кодирование завершается ошибкой.
+func encodeBigramText(text string, bigramCodes, charCodes map[string]string) (string, error) {
+	symbols := textSymbols(text)
+	escape, hasEscape := bigramCodes[escapeSymbol]
+	var encoded strings.Builder
+	for i := 0; i < len(symbols); {
+		if i+1 < len(symbols) {
+			if code, exists := bigramCodes[symbols[i]+symbols[i+1]]; exists {
+				encoded.WriteString(code)
+				i += 2
+				continue
+			}
+		}
+		if !hasEscape {
+			return "", fmt.Errorf("пары с символа %d нет в таблице биграмм, а символа выхода нет", i)
+		}
+		code, exists := charCodes[symbols[i]]
+		if !exists {
+			return "", fmt.Errorf("символа %q нет в таблице одиночных символов", symbols[i])
+		}
+		encoded.WriteString(escape)
+		encoded.WriteString(code)
+		i++
+	}
+	return encoded.String(), nil
+}
+
+func decodeBigramText(encoded string, bigramCodes, charCodes map[string]string) (string, error) {
+	bigramRoot, err := buildDecodeTree(bigramCodes)
+	if err != nil {
+		return "", err
+	}
+	charRoot, err := buildDecodeTree(charCodes)
+	if err != nil {
+		return "", err
+	}
+
+	var decoded strings.Builder
+	root := bigramRoot
+	node := root
+	for i := 0; i < len(encoded); i++ {
+		if encoded[i] != '0' && encoded[i] != '1' {
+			return "", fmt.Errorf("позиция %d: символ %q вместо бита", i, encoded[i])
+		}
+		node = node.child[encoded[i]-'0']
+		if node == nil {
+			return "", fmt.Errorf("позиция %d: %v", i, errUnknownCode)
+		}
+		if !node.leaf {
+			continue
+		}
+		// после кода выхода читается один символ по таблице одиночных символов
+		if root == bigramRoot && node.symbol == escapeSymbol {
+			root = charRoot
+		} else {
+			decoded.WriteString(node.symbol)
+			root = bigramRoot
+		}
+		node = root
+	}
+	if node != bigramRoot {
+		return "", errTruncated
+	}
+	return decoded.String(), nil
+}
+
+// считает, сколько раз при разбиении на пары придётся использовать выход
+func countBigramFallbacks(text string, bigrams []Symbol) int {
+	known := make(map[string]bool, len(bigrams))
+	for _, s := range bigrams {
+		known[s.Char] = true
+	}
+
+	symbols := textSymbols(text)
+	fallbacks := 0
+	for i := 0; i < len(symbols); {
+		if i+1 < len(symbols) && known[symbols[i]+symbols[i+1]] {
+			i += 2
+			continue
+		}
+		fallbacks++
+		i++
+	}
+	return fallbacks
+}
+
+// добавляет в алфавит символ выхода escape с заданным количеством и пересчитывает вероятности
+func withEscapeSymbol(alphabet []Symbol, escape string, count int) []Symbol {
+	if count < 1 {
+		count = 1
+	}
+	result := make([]Symbol, 0, len(alphabet)+1)
+	result = append(result, alphabet...)
+	result = append(result, Symbol{Char: escape, Count: count})
+
+	total := 0
+	for _, s := range result {
+		total += s.Count
+	}
+	for i := range result {
+		result[i].Prob = float64(result[i].Count) / float64(total)
+	}
+
+	sort.Sort(ByProb(result))
+	return result
+}
+
+// среднее число бит закодированного потока на один символ исходного текста
+func bitsPerChar(encoded, text string) float64 {
+	return float64(len(encoded)) / float64(utf8.RuneCountInString(text))
+}
@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestEncodeBigramTextMissingCode(t *testing.T) {
+	bigramCodes := map[string]string{"ab": "0", escapeSymbol: "1"}
+	charCodes := map[string]string{"a": "0", "b": "1"}
+
+	if _, err := encodeBigramText("abc", bigramCodes, charCodes); err == nil {
+		t.Error("нет кода символа 'c', ожидалась ошибка")
+	}
+	if _, err := encodeBigramText("aba", map[string]string{"ab": "0"}, charCodes); err == nil {
+		t.Error("нет символа выхода, ожидалась ошибка")
+	}
+
+	encoded, err := encodeBigramText("aba", bigramCodes, charCodes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := decodeBigramText(encoded, bigramCodes, charCodes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded != "aba" {
+		t.Errorf("декодировано %q, ожидалось %q", decoded, "aba")
+	}
+}
+
+func TestDecodeBigramTextInvalidInput(t *testing.T) {
+	bigramCodes := map[string]string{"ab": "0", escapeSymbol: "1"}
+	charCodes := map[string]string{"a": "0", "b": "1"}
+	for _, encoded := range []string{"02", "0a", "1 ", "0\xff", "1"} {
+		if decoded, err := decodeBigramText(encoded, bigramCodes, charCodes); err == nil {
+			t.Errorf("%q: ожидалась ошибка, декодировано %q", encoded, decoded)
+		}
+	}
+}
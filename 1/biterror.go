@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Распространение одиночной ошибки в коде переменной длины
+//
+// В закодированном тексте инвертируется один случайный бит, после чего декодер
+// читает чужие границы кодов, пока случайно не попадёт на настоящую границу —
+// дальше текст снова декодируется верно (самосинхронизация). Для каждого опыта
+// декодируется окно из bitErrorWindow символов, начиная с символа, в который
+// попала ошибка, и считается, сколько символов исходного текста испорчено:
+// всё, что не совпало ни с началом, ни с концом окна. Если декодер не
+// восстановился до конца окна, опыт считается несинхронизированным, а ущерб —
+// равным длине окна.
+const (
+	bitErrorWindow = 256
+	bitErrorTrials = 2000
+	bitErrorSeed   = 1
+)
+
+// результаты опытов для одного кода
+type bitErrorResult struct {
+	Name      string
+	Damage    []int // испорченных символов в каждом опыте
+	Histogram []int // Histogram[k] — опытов, испортивших ровно k символов
+	Unsynced  int   // опытов без синхронизации в пределах окна
+	Mean      float64
+	Median    int
+	Max       int
+}
+
+// доля опытов с ущербом ровно k символов
+func (r bitErrorResult) share(k int) float64 {
+	if k >= len(r.Histogram) {
+		return 0
+	}
+	return float64(r.Histogram[k]) / float64(len(r.Damage))
+}
+
+// проводит trials опытов с одной инвертированной битовой ошибкой для каждого кода
+func bitErrorExperiment(text string, methods []codeMethod, trials int, seed int64) ([]bitErrorResult, error) {
+	if trials < 1 {
+		return nil, fmt.Errorf("число опытов должно быть положительным, получено %d", trials)
+	}
+	symbols := textSymbols(text)
+	var results []bitErrorResult
+	for _, m := range methods {
+		encoded, err := encodeText(text, m.Codes, true)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", m.Name, err)
+		}
+		if len(encoded) == 0 {
+			return nil, fmt.Errorf("%s: закодированный поток пуст, инвертировать нечего", m.Name)
+		}
+		// offsets[i] — номер первого бита i-го символа
+		offsets := make([]int, len(symbols)+1)
+		for i, symbol := range symbols {
+			offsets[i+1] = offsets[i] + len(m.Codes[symbol])
+		}
+
+		// одно и то же зерно для всех кодов, чтобы опыты были воспроизводимы
+		rng := rand.New(rand.NewSource(seed))
+		result := bitErrorResult{Name: m.Name, Damage: make([]int, trials)}
+		for t := range result.Damage {
+			pos := rng.Intn(len(encoded))
+			first := sort.SearchInts(offsets, pos+1) - 1 // символ, в который попала ошибка
+			last := min(first+bitErrorWindow, len(symbols))
+
+			window := []byte(encoded[offsets[first]:offsets[last]])
+			window[pos-offsets[first]] ^= 1 // '0' <-> '1'
+			original := symbols[first:last]
+			decoded, err := decodeText(string(window), m.Codes)
+			if err != nil {
+				result.Unsynced++
+				result.Damage[t] = len(original)
+				continue
+			}
+			result.Damage[t] = corruptedSymbols(original, textSymbols(decoded))
+		}
+
+		sorted := append([]int{}, result.Damage...)
+		sort.Ints(sorted)
+		sum := 0
+		for _, d := range sorted {
+			sum += d
+		}
+		result.Mean = float64(sum) / float64(trials)
+		result.Median = sorted[trials/2]
+		result.Max = sorted[trials-1]
+		result.Histogram = make([]int, result.Max+1)
+		for _, d := range sorted {
+			result.Histogram[d]++
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// символы исходного окна, не совпавшие ни с общим началом, ни с общим концом
+//
+// После синхронизации хвосты совпадают; случайное совпадение символов перед точкой
+// синхронизации может лишь немного занизить оценку.
+func corruptedSymbols(original, decoded []string) int {
+	limit := min(len(original), len(decoded))
+	prefix := 0
+	for prefix < limit && original[prefix] == decoded[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < limit-prefix && original[len(original)-1-suffix] == decoded[len(decoded)-1-suffix] {
+		suffix++
+	}
+	return len(original) - prefix - suffix
+}
+
+func printBitErrorResults(w io.Writer, results []bitErrorResult) {
+	fmt.Fprintf(w, "  %-14s %10s %10s %10s %18s\n", "Код", "Среднее", "Медиана", "Максимум", "Без синхронизации")
+	for _, r := range results {
+		fmt.Fprintf(w, "  %-14s %10.2f %10d %10d %18d\n", r.Name, r.Mean, r.Median, r.Max, r.Unsynced)
+	}
+}
+
+// распределение ущерба: сколько опытов испортили ровно k символов
+func writeBitErrorCSV(results []bitErrorResult, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	header := []string{"Испорчено символов"}
+	maxDamage := 0
+	for _, r := range results {
+		header = append(header, r.Name)
+		maxDamage = max(maxDamage, r.Max)
+	}
+	writer.Write(header)
+	for k := 0; k <= maxDamage; k++ {
+		row := []string{strconv.Itoa(k)}
+		for _, r := range results {
+			count := 0
+			if k < len(r.Histogram) {
+				count = r.Histogram[k]
+			}
+			row = append(row, strconv.Itoa(count))
+		}
+		writer.Write(row)
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// График распределения ущерба в SVG
+//
+// По горизонтали — число испорченных символов, по вертикали — доля опытов,
+// по линии на код; пунктир отмечает средний ущерб кода. Ось обрезается на
+// наибольшей медиане, умноженной на 4, чтобы редкие длинные хвосты не сжимали график.
+func writeBitErrorSVG(results []bitErrorResult, filename string) error {
+	const (
+		width, height = 720, 420
+		left, right   = 60, 20
+		top, bottom   = 40, 50
+	)
+	colors := []string{"#1f77b4", "#d62728", "#2ca02c", "#ff7f0e"}
+
+	maxX := 4
+	for _, r := range results {
+		maxX = max(maxX, 4*r.Median, int(r.Mean)+2)
+	}
+	maxY := 0.0
+	for _, r := range results {
+		for k := 0; k <= maxX; k++ {
+			maxY = max(maxY, r.share(k))
+		}
+	}
+	maxY = float64(int(maxY*10)+1) / 10
+
+	px := func(x float64) float64 { return left + x/float64(maxX)*(width-left-right) }
+	py := func(y float64) float64 { return height - bottom - y/maxY*(height-top-bottom) }
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="Helvetica" font-size="12">`+"\n", width, height)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="white"/>`+"\n", width, height)
+	fmt.Fprintf(&b, `<text x="%d" y="24" text-anchor="middle" font-size="14">Ущерб от одной битовой ошибки</text>`+"\n", width/2)
+
+	// оси и подписи
+	fmt.Fprintf(&b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="black"/>`+"\n", left, height-bottom, width-right, height-bottom)
+	fmt.Fprintf(&b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="black"/>`+"\n", left, top, left, height-bottom)
+	step := max(1, maxX/10)
+	for x := 0; x <= maxX; x += step {
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="middle">%d</text>`+"\n", px(float64(x)), height-bottom+16, x)
+	}
+	for i := 0; i <= 5; i++ {
+		y := maxY * float64(i) / 5
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" text-anchor="end">%.2f</text>`+"\n", left-6, py(y)+4, y)
+		fmt.Fprintf(&b, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#ddd"/>`+"\n", left, py(y), width-right, py(y))
+	}
+	fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="middle">Испорчено символов</text>`+"\n", (left+width-right)/2, height-12)
+	fmt.Fprintf(&b, `<text x="16" y="%d" text-anchor="middle" transform="rotate(-90 16 %d)">Доля опытов</text>`+"\n", (top+height-bottom)/2, (top+height-bottom)/2)
+
+	for i, r := range results {
+		color := colors[i%len(colors)]
+		points := make([]string, 0, maxX+1)
+		for k := 0; k <= maxX; k++ {
+			points = append(points, fmt.Sprintf("%.1f,%.1f", px(float64(k)), py(r.share(k))))
+		}
+		fmt.Fprintf(&b, `<polyline fill="none" stroke="%s" stroke-width="2" points="%s"/>`+"\n", color, strings.Join(points, " "))
+		if r.Mean <= float64(maxX) {
+			fmt.Fprintf(&b, `<line x1="%.1f" y1="%d" x2="%.1f" y2="%d" stroke="%s" stroke-dasharray="6 4"/>`+"\n",
+				px(r.Mean), top, px(r.Mean), height-bottom, color)
+		}
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end" fill="%s">%s: среднее %.2f</text>`+"\n",
+			width-right-8, top+16+18*i, color, r.Name, r.Mean)
+	}
+	b.WriteString("</svg>\n")
+
+	return os.WriteFile(filename, []byte(b.String()), 0644)
+}
+
+// проводит эксперимент для кодов Шеннона-Фано и Хаффмана и сохраняет распределение и график
+func runBitErrorExperiment(w io.Writer, text string, trials int, seed int64, csvFile, svgFile string) error {
+	alphabet := makeAlphabet(text)
+	methods := []codeMethod{
+		{"shannon-fano", "Шеннон-Фано", generateShannonFanoCodes(alphabet)},
+		{"huffman", "Хаффман", generateCanonicalHuffmanCodes(alphabet)},
+	}
+	results, err := bitErrorExperiment(text, methods, trials, seed)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Одна битовая ошибка: испорчено символов (опытов %d, зерно %d, окно %d):\n", trials, seed, bitErrorWindow)
+	printBitErrorResults(w, results)
+	if err := writeBitErrorCSV(results, csvFile); err != nil {
+		return err
+	}
+	return writeBitErrorSVG(results, svgFile)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBitErrorExperiment(t *testing.T) {
+	// недопустимый байт — отдельный символ, как и в encodeText
+	text := strings.Repeat("абв\xffгде абв\n", 20)
+	alphabet := makeAlphabet(text)
+	methods := []codeMethod{
+		{"shannon-fano", "Шеннон-Фано", generateShannonFanoCodes(alphabet)},
+		{"huffman", "Хаффман", generateCanonicalHuffmanCodes(alphabet)},
+	}
+	const trials = 300
+	results, err := bitErrorExperiment(text, methods, trials, bitErrorSeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(methods) {
+		t.Fatalf("результатов %d, ожидалось %d", len(results), len(methods))
+	}
+	for _, r := range results {
+		if len(r.Damage) != trials {
+			t.Errorf("%s: опытов %d, ожидалось %d", r.Name, len(r.Damage), trials)
+		}
+		total := 0
+		for k, count := range r.Histogram {
+			total += count
+			if count > 0 && k > bitErrorWindow {
+				t.Errorf("%s: ущерб %d больше окна %d", r.Name, k, bitErrorWindow)
+			}
+		}
+		if total != trials {
+			t.Errorf("%s: в гистограмме %d опытов, ожидалось %d", r.Name, total, trials)
+		}
+		if r.Mean < 0 || r.Mean > float64(r.Max) || r.Median > r.Max {
+			t.Errorf("%s: среднее %.2f, медиана %d, максимум %d", r.Name, r.Mean, r.Median, r.Max)
+		}
+	}
+
+	// то же зерно — те же опыты
+	again, err := bitErrorExperiment(text, methods, trials, bitErrorSeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(results, again) {
+		t.Error("повторный прогон с тем же зерном дал другие результаты")
+	}
+}
+
+func TestBitErrorExperimentErrors(t *testing.T) {
+	codes := map[string]string{"a": "0"}
+	methods := []codeMethod{{"huffman", "Хаффман", codes}}
+	if _, err := bitErrorExperiment("aaa", methods, 0, bitErrorSeed); err == nil {
+		t.Error("ноль опытов: ожидалась ошибка")
+	}
+	if _, err := bitErrorExperiment("", methods, 10, bitErrorSeed); err == nil {
+		t.Error("пустой текст: ожидалась ошибка")
+	}
+}
+
+func TestCorruptedSymbols(t *testing.T) {
+	for _, c := range []struct {
+		original, decoded string
+		want              int
+	}{
+		{"абвгд", "абвгд", 0},
+		{"абвгд", "абxгд", 1},
+		{"абвгд", "аxxxxxгд", 2},
+		{"абвгд", "гд", 3},
+		{"аб\xffгд", "аб\xfeгд", 1},
+	} {
+		if got := corruptedSymbols(textSymbols(c.original), textSymbols(c.decoded)); got != c.want {
+			t.Errorf("%q → %q: испорчено %d, ожидалось %d", c.original, c.decoded, got, c.want)
+		}
+	}
+}
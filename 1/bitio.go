@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"io"
+)
+
+// побитовая запись: биты накапливаются старшим битом вперёд и сбрасываются целыми байтами
+type bitWriter struct {
+	w     io.ByteWriter
+	cur   byte   // текущий неполный байт
+	n     uint   // сколько бит уже лежит в cur
+	count uint64 // всего записано бит
+	err   error
+}
+
+func newBitWriter(w io.ByteWriter) *bitWriter {
+	return &bitWriter{w: w}
+}
+
+func (bw *bitWriter) writeBit(bit uint) {
+	if bw.err != nil {
+		return
+	}
+	bw.cur = bw.cur<<1 | byte(bit&1)
+	bw.n++
+	bw.count++
+	if bw.n == 8 {
+		bw.err = bw.w.WriteByte(bw.cur)
+		bw.cur, bw.n = 0, 0
+	}
+}
+
+// записывает n младших бит значения v, начиная со старшего
+func (bw *bitWriter) writeBits(v uint64, n int) {
+	for i := n - 1; i >= 0; i-- {
+		bw.writeBit(uint(v>>uint(i)) & 1)
+	}
+}
+
+// записывает код вида "0110"
+func (bw *bitWriter) writeCode(code string) {
+	for i := 0; i < len(code); i++ {
+		bw.writeBit(uint(code[i] - '0'))
+	}
+}
+
+// дописывает последний неполный байт нулями
+func (bw *bitWriter) flush() error {
+	if bw.err == nil && bw.n > 0 {
+		bw.err = bw.w.WriteByte(bw.cur << (8 - bw.n))
+		bw.cur, bw.n = 0, 0
+	}
+	return bw.err
+}
+
+// побитовое чтение в том же порядке, в каком пишет bitWriter
+type bitReader struct {
+	r   io.ByteReader
+	cur byte
+	n   uint // сколько непрочитанных бит осталось в cur
+}
+
+func newBitReader(r io.ByteReader) *bitReader {
+	return &bitReader{r: r}
+}
+
+var errUnexpectedEnd = errors.New("битовый поток закончился раньше времени")
+
+func (br *bitReader) readBit() (uint, error) {
+	if br.n == 0 {
+		b, err := br.r.ReadByte()
+		if err == io.EOF {
+			return 0, errUnexpectedEnd
+		}
+		if err != nil {
+			return 0, err
+		}
+		br.cur, br.n = b, 8
+	}
+	br.n--
+	return uint(br.cur>>br.n) & 1, nil
+}
+
+func (br *bitReader) readBits(n int) (uint64, error) {
+	var v uint64
+	for i := 0; i < n; i++ {
+		bit, err := br.readBit()
+		if err != nil {
+			return 0, err
+		}
+		v = v<<1 | uint64(bit)
+	}
+	return v, nil
+}
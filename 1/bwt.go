@@ -0,0 +1,353 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+	"strconv"
+)
+
+// Преобразование Барроуза-Уилера
+//
+// К тексту дописывается терминатор, который меньше любого символа и встречается
+// один раз. Тогда порядок циклических сдвигов совпадает с порядком суффиксов,
+// и последний столбец матрицы сдвигов читается прямо из суффиксного массива.
+// Возвращается последний столбец без терминатора и позиция, где он стоял.
+func bwtTransform(text string) ([]rune, int) {
+	runes := textRunes(text)
+	alphabet := distinctRunes(runes)
+	rank := make(map[rune]int, len(alphabet))
+	for i, r := range alphabet {
+		rank[r] = i + 1
+	}
+
+	s := make([]int, len(runes)+1)
+	for i, r := range runes {
+		s[i] = rank[r]
+	}
+	sa := suffixArray(s, len(alphabet)+1)
+
+	last := make([]rune, 0, len(runes))
+	primary := 0
+	for i, p := range sa {
+		if p == 0 {
+			primary = i
+			continue
+		}
+		last = append(last, runes[p-1])
+	}
+	return last, primary
+}
+
+// Обратное преобразование через LF-отображение: символ в последнем столбце
+// и его вхождение в первом столбце связаны номером вхождения этого символа.
+func inverseBWT(last []rune, primary int) string {
+	n := len(last) + 1
+	alphabet := distinctRunes(last)
+	rank := make(map[rune]int, len(alphabet))
+	for i, r := range alphabet {
+		rank[r] = i + 1
+	}
+
+	// L — последний столбец с терминатором (0) на своём месте
+	L := make([]int, 0, n)
+	for i, r := range last {
+		if i == primary {
+			L = append(L, 0)
+		}
+		L = append(L, rank[r])
+	}
+	if primary == len(last) {
+		L = append(L, 0)
+	}
+
+	// first[c] — первая строка первого столбца, начинающаяся с символа c
+	first := make([]int, len(alphabet)+2)
+	for _, c := range L {
+		first[c+1]++
+	}
+	for c := 1; c < len(first); c++ {
+		first[c] += first[c-1]
+	}
+	lf := make([]int, n)
+	seen := make([]int, len(alphabet)+1)
+	for i, c := range L {
+		lf[i] = first[c] + seen[c]
+		seen[c]++
+	}
+
+	// строка 0 начинается с терминатора, значит её последний символ — последний символ текста
+	result := make([]rune, n-1)
+	row := 0
+	for k := n - 2; k >= 0; k-- {
+		result[k] = alphabet[L[row]-1]
+		row = lf[row]
+	}
+	return runesText(result)
+}
+
+// Суффиксный массив удвоением префиксов
+//
+// На каждом шаге суффиксы упорядочены по первым k символам; пара рангов
+// (rank[i], rank[i+k]) упорядочивает их по 2k символам. Пары сортируются
+// подсчётом за линейное время, так что всего O(n log n).
+// s[len(s)-1] должен быть уникальным минимальным символом 0, остальные — от 1 до sigma-1.
+func suffixArray(s []int, sigma int) []int {
+	n := len(s)
+	sa := make([]int, n)
+	rank := make([]int, n)
+	tmp := make([]int, n)
+	copy(rank, s)
+
+	countingSort := func(keys []int, src, dst []int, size int) {
+		count := make([]int, size+1)
+		for _, p := range src {
+			count[keys[p]+1]++
+		}
+		for i := 1; i <= size; i++ {
+			count[i] += count[i-1]
+		}
+		for _, p := range src {
+			dst[count[keys[p]]] = p
+			count[keys[p]]++
+		}
+	}
+
+	for i := range tmp {
+		tmp[i] = i
+	}
+	countingSort(rank, tmp, sa, max(sigma, n))
+
+	for k := 1; ; k *= 2 {
+		// сначала по второму ключу: суффиксы короче k идут первыми, остальные — в порядке sa
+		j := 0
+		for i := n - k; i < n; i++ {
+			tmp[j] = i
+			j++
+		}
+		for _, p := range sa {
+			if p >= k {
+				tmp[j] = p - k
+				j++
+			}
+		}
+		// затем устойчиво по первому ключу
+		countingSort(rank, tmp, sa, max(sigma, n))
+
+		second := func(p int) int {
+			if p+k < n {
+				return rank[p+k]
+			}
+			return -1
+		}
+		tmp[sa[0]] = 0
+		for i := 1; i < n; i++ {
+			tmp[sa[i]] = tmp[sa[i-1]]
+			if rank[sa[i]] != rank[sa[i-1]] || second(sa[i]) != second(sa[i-1]) {
+				tmp[sa[i]]++
+			}
+		}
+		rank, tmp = tmp, rank
+		if rank[sa[n-1]] == n-1 {
+			break
+		}
+	}
+	return sa
+}
+
+func distinctRunes(runes []rune) []rune {
+	seen := make(map[rune]bool)
+	var result []rune
+	for _, r := range runes {
+		if !seen[r] {
+			seen[r] = true
+			result = append(result, r)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result
+}
+
+// Move-to-front: каждый символ заменяется его номером в списке, после чего
+// переносится в начало. Повторы после BWT превращаются в длинные серии нулей.
+func mtfEncode(runes []rune, alphabet []rune) []int {
+	list := append([]rune{}, alphabet...)
+	result := make([]int, len(runes))
+	for i, r := range runes {
+		j := 0
+		for list[j] != r {
+			j++
+		}
+		result[i] = j
+		copy(list[1:j+1], list[:j])
+		list[0] = r
+	}
+	return result
+}
+
+func mtfDecode(ranks []int, alphabet []rune) []rune {
+	list := append([]rune{}, alphabet...)
+	result := make([]rune, len(ranks))
+	for i, j := range ranks {
+		r := list[j]
+		result[i] = r
+		copy(list[1:j+1], list[:j])
+		list[0] = r
+	}
+	return result
+}
+
+// Кодирование серий нулей как в bzip2
+//
+// Длина серии записывается в биективной двоичной системе цифрами RUNA (вес 1) и RUNB (вес 2),
+// младшая цифра первой. Ненулевой номер k становится k+1.
+const (
+	rleRunA = 0
+	rleRunB = 1
+)
+
+func zeroRunEncode(ranks []int) []int {
+	var result []int
+	for i := 0; i < len(ranks); {
+		if ranks[i] != 0 {
+			result = append(result, ranks[i]+1)
+			i++
+			continue
+		}
+		run := 0
+		for i < len(ranks) && ranks[i] == 0 {
+			run++
+			i++
+		}
+		for run > 0 {
+			if run&1 == 1 {
+				result = append(result, rleRunA)
+				run = (run - 1) / 2
+			} else {
+				result = append(result, rleRunB)
+				run = (run - 2) / 2
+			}
+		}
+	}
+	return result
+}
+
+func zeroRunDecode(symbols []int) []int {
+	var result []int
+	run, weight := 0, 1
+	flush := func() {
+		for ; run > 0; run-- {
+			result = append(result, 0)
+		}
+		weight = 1
+	}
+	for _, s := range symbols {
+		switch s {
+		case rleRunA:
+			run += weight
+			weight *= 2
+		case rleRunB:
+			run += 2 * weight
+			weight *= 2
+		default:
+			flush()
+			result = append(result, s-1)
+		}
+	}
+	flush()
+	return result
+}
+
+func intAlphabet(values []int) []Symbol {
+	counts := make(map[string]int)
+	for _, v := range values {
+		counts[strconv.Itoa(v)]++
+	}
+	return alphabetFromCounts(counts)
+}
+
+// результат конвейера BWT + MTF + RLE + Хаффман
+type bwtResult struct {
+	Primary     int
+	Alphabet    []rune
+	MTF         []int // выход move-to-front, для оценки энтропии
+	Symbols     int   // число символов после RLE
+	Codes       map[string]string
+	Data        []byte
+	Bits        uint64
+	MTFEntropy  float64
+	RLEEntropy  float64
+	TotalLength int // размер данных с таблицей кодов, алфавитом, номером строки и числом символов
+}
+
+func bwtEncode(text string) (*bwtResult, error) {
+	last, primary := bwtTransform(text)
+	alphabet := distinctRunes(last)
+	mtf := mtfEncode(last, alphabet)
+	rle := zeroRunEncode(mtf)
+
+	rleAlphabet := intAlphabet(rle)
+	codes := generateHuffmanCodes(rleAlphabet)
+
+	var buf bytes.Buffer
+	bw := newBitWriter(&buf)
+	for _, v := range rle {
+		bw.writeCode(codes[strconv.Itoa(v)])
+	}
+	bits := bw.count
+	if err := bw.flush(); err != nil {
+		return nil, err
+	}
+
+	result := &bwtResult{
+		Primary:    primary,
+		Alphabet:   alphabet,
+		MTF:        mtf,
+		Symbols:    len(rle),
+		Codes:      codes,
+		Data:       buf.Bytes(),
+		Bits:       bits,
+		MTFEntropy: calculateEntropy(intAlphabet(mtf)),
+		RLEEntropy: calculateEntropy(rleAlphabet),
+	}
+	var varint [binary.MaxVarintLen64]byte
+	result.TotalLength = len(result.Data) + headerSize(codes) + len(runesText(alphabet)) +
+		binary.PutUvarint(varint[:], uint64(len(alphabet))) +
+		binary.PutUvarint(varint[:], uint64(primary)) +
+		binary.PutUvarint(varint[:], uint64(len(rle)))
+	return result, nil
+}
+
+func bwtDecode(b *bwtResult) (string, error) {
+	root, err := buildDecodeTree(b.Codes)
+	if err != nil {
+		return "", err
+	}
+	br := newBitReader(bytes.NewReader(b.Data))
+	rle := make([]int, 0, b.Symbols)
+	for len(rle) < b.Symbols {
+		symbol, err := readSymbol(br, root)
+		if err != nil {
+			return "", err
+		}
+		v, err := strconv.Atoi(symbol)
+		if err != nil {
+			return "", err
+		}
+		rle = append(rle, v)
+	}
+
+	last := mtfDecode(zeroRunDecode(rle), b.Alphabet)
+	return inverseBWT(last, b.Primary), nil
+}
+
+// сколько процентов выхода MTF составляют нули
+func zeroShare(ranks []int) float64 {
+	zeros := 0
+	for _, r := range ranks {
+		if r == 0 {
+			zeros++
+		}
+	}
+	return 100 * float64(zeros) / float64(len(ranks))
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func bwtRoundTripInputs() map[string]string {
+	inputs := roundTripInputs()
+	inputs["период ab"] = strings.Repeat("ab", 500)
+	inputs["период abc"] = strings.Repeat("abc", 333) + "ab"
+	inputs["один символ много раз"] = strings.Repeat("a", 1000)
+	inputs["период из кириллицы"] = strings.Repeat("да", 7)
+	return inputs
+}
+
+// суффиксный массив прямой сортировкой суффиксов
+func naiveSuffixArray(s []int) []int {
+	sa := make([]int, len(s))
+	for i := range sa {
+		sa[i] = i
+	}
+	less := func(a, b int) bool {
+		for a < len(s) && b < len(s) {
+			if s[a] != s[b] {
+				return s[a] < s[b]
+			}
+			a++
+			b++
+		}
+		return a == len(s)
+	}
+	sort.Slice(sa, func(i, j int) bool { return less(sa[i], sa[j]) })
+	return sa
+}
+
+func TestSuffixArray(t *testing.T) {
+	for name, input := range bwtRoundTripInputs() {
+		runes := textRunes(input)
+		alphabet := distinctRunes(runes)
+		rank := make(map[rune]int, len(alphabet))
+		for i, r := range alphabet {
+			rank[r] = i + 1
+		}
+		s := make([]int, len(runes)+1)
+		for i, r := range runes {
+			s[i] = rank[r]
+		}
+		if got, want := suffixArray(s, len(alphabet)+1), naiveSuffixArray(s); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: суффиксный массив %v, ожидался %v", name, got, want)
+		}
+	}
+}
+
+func TestInverseBWT(t *testing.T) {
+	for name, input := range bwtRoundTripInputs() {
+		last, primary := bwtTransform(input)
+		if got := inverseBWT(last, primary); got != input {
+			t.Errorf("%s: восстановлено %q", name, got)
+		}
+	}
+	// bwtTransform("banana") без терминатора: annb$aa -> annbaa, терминатор на месте 4
+	if last, primary := bwtTransform("banana"); string(last) != "annbaa" || primary != 4 {
+		t.Errorf("banana: последний столбец %q, терминатор на месте %d", string(last), primary)
+	}
+}
+
+func TestZeroRunRoundTrip(t *testing.T) {
+	cases := [][]int{nil, {0}, {3}, {0, 0, 5, 0}}
+	for run := 1; run <= 20; run++ {
+		cases = append(cases, make([]int, run), append(make([]int, run), 1))
+	}
+	for _, ranks := range cases {
+		encoded := zeroRunEncode(ranks)
+		if got := zeroRunDecode(encoded); fmt.Sprint(got) != fmt.Sprint(ranks) {
+			t.Errorf("%v: закодировано в %v, восстановлено %v", ranks, encoded, got)
+		}
+	}
+}
+
+func TestBWTRoundTrip(t *testing.T) {
+	for name, input := range bwtRoundTripInputs() {
+		last, _ := bwtTransform(input)
+		alphabet := distinctRunes(last)
+		if got := mtfDecode(mtfEncode(last, alphabet), alphabet); string(got) != string(last) {
+			t.Errorf("%s: MTF восстановил %q", name, string(got))
+		}
+
+		encoded, err := bwtEncode(input)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		decoded, err := bwtDecode(encoded)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if decoded != input {
+			t.Errorf("%s: восстановлено %q", name, decoded)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import "sort"
+
+// Канонические коды Хаффмана
+//
+// Коды определяются только длинами: символы упорядочиваются по (длина, символ),
+// и каждый следующий код получается из предыдущего прибавлением единицы
+// со сдвигом влево при переходе к большей длине.
+// Поэтому таблицу достаточно хранить как пары (символ, длина).
+func generateCanonicalHuffmanCodes(alphabet []Symbol) map[string]string {
+	return canonicalCodes(codeLengths(generateHuffmanCodes(alphabet)))
+}
+
+func codeLengths(codes map[string]string) map[string]int {
+	lengths := make(map[string]int, len(codes))
+	for symbol, code := range codes {
+		lengths[symbol] = len(code)
+	}
+	return lengths
+}
+
+// символы в каноническом порядке: по возрастанию длины кода, при равной длине по строке
+func canonicalOrder(lengths map[string]int) []string {
+	symbols := make([]string, 0, len(lengths))
+	for symbol := range lengths {
+		symbols = append(symbols, symbol)
+	}
+	sort.Slice(symbols, func(i, j int) bool {
+		li, lj := lengths[symbols[i]], lengths[symbols[j]]
+		if li != lj {
+			return li < lj
+		}
+		return symbols[i] < symbols[j]
+	})
+	return symbols
+}
+
+func canonicalCodes(lengths map[string]int) map[string]string {
+	codes := make(map[string]string, len(lengths))
+	code := uint64(0)
+	prevLength := 0
+	for i, symbol := range canonicalOrder(lengths) {
+		// пустой код недопустим: единственный символ получает код "0"
+		length := max(lengths[symbol], 1)
+		if i > 0 {
+			code++
+		}
+		code <<= uint(length - prevLength)
+		prevLength = length
+		codes[symbol] = formatCode(code, length)
+	}
+	return codes
+}
+
+// проверяет, совпадает ли таблица со своей канонической формой
+func isCanonical(codes map[string]string) bool {
+	canonical := canonicalCodes(codeLengths(codes))
+	for symbol, code := range codes {
+		if canonical[symbol] != code {
+			return false
+		}
+	}
+	return true
+}
+
+// записывает length младших бит числа в виде строки из '0' и '1'
+func formatCode(code uint64, length int) string {
+	buf := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		buf[i] = '0' + byte(code&1)
+		code >>= 1
+	}
+	return string(buf)
+}
@@ -0,0 +1,474 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Интерфейс командной строки
+//
+//	lab1 [demo]   полный прогон всех методов на text.txt
+//	lab1 analyze  энтропия, избыточность и эффективность кодов для файла
+//	lab1 encode   сжатие файла в контейнер
+//	lab1 decode   восстановление файла из контейнера
+//	lab1 table    таблица кодов в CSV
+//	lab1 tree     дерево кода в формате Graphviz DOT
+//	lab1 cross    код по обучающему файлу на тестовом файле
+//	lab1 biterrors распространение одиночной битовой ошибки
+//
+// Код завершения: 0 — успех, 1 — ошибка при работе, 2 — неверные аргументы.
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// алгоритмы построения кода, доступные из командной строки
+var codeAlgorithms = map[string]func([]Symbol) map[string]string{
+	"shannon-fano": generateShannonFanoCodes,
+	"huffman":      generateCanonicalHuffmanCodes,
+}
+
+// единицы кодирования: на что разбивается входной файл
+var codingUnits = map[string]symbolReader{
+	"char":   readRuneSymbol,
+	"bigram": readBigramSymbol,
+	"byte":   readByteSymbol,
+}
+
+// подписи единиц кодирования в отчётах
+var unitTitles = map[string]string{
+	"char":   "Символы",
+	"bigram": "Биграммы без перекрытия",
+	"byte":   "Байты",
+}
+
+// ошибка в аргументах командной строки
+type usageError struct{ msg string }
+
+func (e usageError) Error() string { return e.msg }
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	command := "demo"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	commands := map[string]func(args []string, stdout, stderr io.Writer) error{
+		"demo":      demoCommand,
+		"analyze":   analyzeCommand,
+		"encode":    encodeCommand,
+		"decode":    decodeCommand,
+		"table":     tableCommand,
+		"tree":      treeCommand,
+		"cross":     crossCommand,
+		"biterrors": bitErrorsCommand,
+	}
+	cmd, exists := commands[command]
+	if !exists {
+		fmt.Fprintf(stderr, "неизвестная команда %q\n", command)
+		printUsage(stderr)
+		return exitUsage
+	}
+
+	err := cmd(args, stdout, stderr)
+	var usage usageError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, flag.ErrHelp):
+		return exitOK
+	case errors.As(err, &usage):
+		if usage.msg != "" {
+			fmt.Fprintln(stderr, usage.msg)
+		}
+		return exitUsage
+	default:
+		fmt.Fprintln(stderr, "ошибка:", err)
+		return exitError
+	}
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "использование: lab1 [demo|analyze|encode|decode|table|tree|cross|biterrors] [флаги]")
+	fmt.Fprintln(w, "  lab1 <команда> -h — флаги команды")
+}
+
+// набор флагов команды; ошибки разбора и справку печатает сам flag
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	return fs
+}
+
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return usageError{} // flag уже напечатал ошибку и справку
+	}
+	if fs.NArg() > 0 {
+		return usageError{fmt.Sprintf("%s: лишние аргументы %q", fs.Name(), fs.Args())}
+	}
+	return nil
+}
+
+func algorithmFlag(fs *flag.FlagSet) *string {
+	return fs.String("algorithm", "huffman", "алгоритм: "+strings.Join(sortedKeys(codeAlgorithms), ", "))
+}
+
+func unitFlag(fs *flag.FlagSet) *string {
+	return fs.String("unit", "char", "единица кодирования: "+strings.Join(sortedKeys(codingUnits), ", "))
+}
+
+func lookupAlgorithm(name string) (func([]Symbol) map[string]string, error) {
+	generate, exists := codeAlgorithms[name]
+	if !exists {
+		return nil, usageError{fmt.Sprintf("неизвестный алгоритм %q, доступны: %s", name, strings.Join(sortedKeys(codeAlgorithms), ", "))}
+	}
+	return generate, nil
+}
+
+func lookupUnit(name string) (symbolReader, error) {
+	read, exists := codingUnits[name]
+	if !exists {
+		return nil, usageError{fmt.Sprintf("неизвестная единица %q, доступны: %s", name, strings.Join(sortedKeys(codingUnits), ", "))}
+	}
+	return read, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// путь выходного файла: относительные имена кладутся в каталог dir
+func outputPath(dir, name string) (string, error) {
+	if filepath.IsAbs(name) {
+		return name, nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// алфавит файла для выбранной единицы кодирования
+func fileAlphabet(filename string, read symbolReader) ([]Symbol, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return countFrequenciesStream(file, read)
+}
+
+func demoCommand(args []string, stdout, stderr io.Writer) error {
+	fs := newFlagSet("demo", stderr)
+	input := fs.String("input", "text.txt", "исходный текст")
+	dir := fs.String("dir", ".", "каталог для файлов результатов")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	return runDemo(*input, *dir, stdout)
+}
+
+func analyzeCommand(args []string, stdout, stderr io.Writer) error {
+	fs := newFlagSet("analyze", stderr)
+	input := fs.String("input", "text.txt", "анализируемый файл")
+	unitName := unitFlag(fs)
+	format := fs.String("format", "text", "формат отчёта: text, json, markdown")
+	dir := fs.String("dir", ".", "каталог для alphabet.csv")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	read, err := lookupUnit(*unitName)
+	if err != nil {
+		return err
+	}
+	writers := map[string]func(io.Writer, analysisReport) error{
+		"text":     printAnalysisReport,
+		"json":     writeReportJSON,
+		"markdown": writeReportMarkdown,
+	}
+	write, exists := writers[*format]
+	if !exists {
+		return usageError{fmt.Sprintf("неизвестный формат %q, доступны: %s", *format, strings.Join(sortedKeys(writers), ", "))}
+	}
+
+	alphabet, err := fileAlphabet(*input, read)
+	if err != nil {
+		return err
+	}
+	if len(alphabet) == 0 {
+		return fmt.Errorf("%s пуст", *input)
+	}
+	path, err := outputPath(*dir, "alphabet.csv")
+	if err != nil {
+		return err
+	}
+	if err := writeAlphabetToCSV(alphabet, path); err != nil {
+		return err
+	}
+
+	report := analysisReport{
+		Source:    *input,
+		Alphabets: []alphabetReport{newAlphabetReport(*unitName, unitTitles[*unitName], alphabet, codeMethods(alphabet))},
+	}
+	return write(stdout, report)
+}
+
+// отчёт в консоль в том же виде, что и остальной вывод лабораторной
+func printAnalysisReport(w io.Writer, report analysisReport) error {
+	for _, a := range report.Alphabets {
+		fmt.Fprintf(w, "Символов алфавита: %d\n", a.Symbols)
+		fmt.Fprintf(w, "Энтропия: %.4f бит/символ\n", a.Entropy)
+		fmt.Fprintf(w, "Длина равномерного кода: %.0f бит\n", a.UniformLength)
+		fmt.Fprintf(w, "Избыточность: %.4f бит\n", a.Redundancy)
+		for _, c := range a.Coders {
+			fmt.Fprintf(w, "%s: средняя длина %.4f бит, эффективность %.4f\n", c.Name, c.AverageLength, c.Efficiency)
+		}
+	}
+	return nil
+}
+
+func encodeCommand(args []string, stdout, stderr io.Writer) error {
+	fs := newFlagSet("encode", stderr)
+	input := fs.String("input", "text.txt", "сжимаемый файл")
+	output := fs.String("output", "encoded.bin", "сжатый файл")
+	algorithm := algorithmFlag(fs)
+	unitName := unitFlag(fs)
+	table := fs.String("table", "", "CSV с готовой таблицей кодов; таблица не пишется в сжатый файл")
+	strict := fs.Bool("strict", false, "с -table: символ, которого нет в таблице, — ошибка, даже если есть символ выхода")
+	dir := fs.String("dir", ".", "каталог для выходного файла")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	generate, err := lookupAlgorithm(*algorithm)
+	if err != nil {
+		return err
+	}
+	read, err := lookupUnit(*unitName)
+	if err != nil {
+		return err
+	}
+	path, err := outputPath(*dir, *output)
+	if err != nil {
+		return err
+	}
+
+	if *table != "" {
+		codes, err := loadCodesFromCSV(*table)
+		if err != nil {
+			return err
+		}
+		if err := encodeFileWithTable(*input, path, codes, read, *strict); err != nil {
+			return err
+		}
+	} else if _, err := encodeFile(*input, path, generate, read); err != nil {
+		return err
+	}
+	originalSize, compressedSize := fileSize(*input), fileSize(path)
+	fmt.Fprintf(stdout, "%s → %s: %d → %d байт", *input, path, originalSize, compressedSize)
+	if compressedSize > 0 {
+		fmt.Fprintf(stdout, ", коэффициент сжатия %.4f", float64(originalSize)/float64(compressedSize))
+	}
+	fmt.Fprintln(stdout)
+	return nil
+}
+
+// контейнер хранит таблицу кодов, поэтому алгоритм и единица для декодирования не нужны;
+// если файл сжат с -table, ту же таблицу нужно передать и сюда
+func decodeCommand(args []string, stdout, stderr io.Writer) error {
+	fs := newFlagSet("decode", stderr)
+	input := fs.String("input", "encoded.bin", "сжатый файл")
+	output := fs.String("output", "decoded.txt", "восстановленный файл")
+	table := fs.String("table", "", "CSV с таблицей кодов для файла, сжатого с -table")
+	dir := fs.String("dir", ".", "каталог для выходного файла")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	path, err := outputPath(*dir, *output)
+	if err != nil {
+		return err
+	}
+	if *table != "" {
+		codes, err := loadCodesFromCSV(*table)
+		if err != nil {
+			return err
+		}
+		if err := decodeFileWithTable(*input, path, codes); err != nil {
+			return err
+		}
+	} else if err := decodeFile(*input, path); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "%s → %s: %d байт\n", *input, path, fileSize(path))
+	return nil
+}
+
+func tableCommand(args []string, stdout, stderr io.Writer) error {
+	fs := newFlagSet("table", stderr)
+	input := fs.String("input", "text.txt", "файл, по которому строится код")
+	output := fs.String("output", "", "файл таблицы (по умолчанию <алгоритм>_codes.csv)")
+	algorithm := algorithmFlag(fs)
+	unitName := unitFlag(fs)
+	escape := fs.Bool("escape", false, "добавить символ выхода для символов, которых нет в файле (\\xFF\\xFF\\xFF в CSV)")
+	dir := fs.String("dir", ".", "каталог для выходного файла")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	generate, err := lookupAlgorithm(*algorithm)
+	if err != nil {
+		return err
+	}
+	if *escape {
+		generate = withEscape(generate)
+	}
+	read, err := lookupUnit(*unitName)
+	if err != nil {
+		return err
+	}
+	name := *output
+	if name == "" {
+		name = strings.ReplaceAll(*algorithm, "-", "_") + "_codes.csv"
+	}
+	path, err := outputPath(*dir, name)
+	if err != nil {
+		return err
+	}
+
+	alphabet, err := fileAlphabet(*input, read)
+	if err != nil {
+		return err
+	}
+	codes := generate(alphabet)
+	if report := validateCodeTable(codes, alphabet); !report.ok() {
+		return fmt.Errorf("таблица %s некорректна: %s", path, report)
+	}
+	if err := writeCodesToCSV(codes, path); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "%s: %d кодов, средняя длина %.4f бит\n", path, len(codes), calculateAverageCodeLength(alphabet, codes))
+	return nil
+}
+
+func treeCommand(args []string, stdout, stderr io.Writer) error {
+	fs := newFlagSet("tree", stderr)
+	input := fs.String("input", "text.txt", "файл, по которому строится код")
+	output := fs.String("output", "", "файл DOT (по умолчанию <алгоритм>_tree.dot)")
+	algorithm := algorithmFlag(fs)
+	unitName := unitFlag(fs)
+	dir := fs.String("dir", ".", "каталог для выходного файла")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	generate, err := lookupAlgorithm(*algorithm)
+	if err != nil {
+		return err
+	}
+	read, err := lookupUnit(*unitName)
+	if err != nil {
+		return err
+	}
+	name := strings.ReplaceAll(*algorithm, "-", "_")
+	filename := *output
+	if filename == "" {
+		filename = name + "_tree.dot"
+	}
+	path, err := outputPath(*dir, filename)
+	if err != nil {
+		return err
+	}
+
+	alphabet, err := fileAlphabet(*input, read)
+	if err != nil {
+		return err
+	}
+	// дерево той же таблицы, что пишет команда table
+	root, err := treeFromCodes(generate(alphabet), alphabet)
+	if err != nil {
+		return err
+	}
+	if err := writeTreeToDOT(root, name, path); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "%s: дерево из %d листьев\n", path, len(alphabet))
+	return nil
+}
+
+func crossCommand(args []string, stdout, stderr io.Writer) error {
+	fs := newFlagSet("cross", stderr)
+	trainFile := fs.String("train", "", "обучающий файл, по которому строятся коды")
+	testFile := fs.String("test", "", "тестовый файл, который кодируется")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *trainFile == "" || *testFile == "" {
+		return usageError{"cross: нужны оба флага -train и -test"}
+	}
+
+	train, err := os.ReadFile(*trainFile)
+	if err != nil {
+		return err
+	}
+	test, err := os.ReadFile(*testFile)
+	if err != nil {
+		return err
+	}
+	if len(train) == 0 || len(test) == 0 {
+		return errors.New("обучающий и тестовый файлы не должны быть пустыми")
+	}
+	report, err := crossCorpus(string(train), string(test))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Код по %s на %s:\n", *trainFile, *testFile)
+	printCrossCorpusReport(stdout, report)
+	return nil
+}
+
+func bitErrorsCommand(args []string, stdout, stderr io.Writer) error {
+	fs := newFlagSet("biterrors", stderr)
+	input := fs.String("input", "text.txt", "текст для кодирования")
+	trials := fs.Int("trials", bitErrorTrials, "число опытов с одной ошибкой на код")
+	seed := fs.Int64("seed", bitErrorSeed, "зерно генератора случайных позиций")
+	dir := fs.String("dir", ".", "каталог для bit_errors.csv и bit_errors.svg")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *trials < 1 {
+		return usageError{"biterrors: -trials должно быть положительным"}
+	}
+	content, err := os.ReadFile(*input)
+	if err != nil {
+		return err
+	}
+	if len(content) == 0 {
+		return fmt.Errorf("%s пуст", *input)
+	}
+	csvPath, err := outputPath(*dir, "bit_errors.csv")
+	if err != nil {
+		return err
+	}
+	svgPath, err := outputPath(*dir, "bit_errors.svg")
+	if err != nil {
+		return err
+	}
+	return runBitErrorExperiment(stdout, string(content), *trials, *seed, csvPath, svgPath)
+}
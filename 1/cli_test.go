@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// ошибки разбора флагов и неизвестные команды печатаются в переданный stderr
+func TestRunUsageErrors(t *testing.T) {
+	for _, args := range [][]string{
+		{"nosuch"},
+		{"demo", "-nosuch"},
+		{"encode", "-algorithm", "nosuch"},
+		{"cross", "-train", "text.txt"},
+	} {
+		var stdout, stderr bytes.Buffer
+		if code := run(args, &stdout, &stderr); code != exitUsage {
+			t.Errorf("%q: код завершения %d, ожидался %d", args, code, exitUsage)
+		}
+		if stderr.Len() == 0 {
+			t.Errorf("%q: в stderr ничего не напечатано", args)
+		}
+		if stdout.Len() != 0 {
+			t.Errorf("%q: в stdout напечатано %q", args, stdout.String())
+		}
+	}
+}
+
+func TestRunHelp(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"demo", "-h"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("код завершения %d, ожидался %d", code, exitOK)
+	}
+	if !strings.Contains(stderr.String(), "-dir") {
+		t.Errorf("в справке demo нет флага -dir:\n%s", stderr.String())
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Загрузка таблиц кодов из CSV
+//
+// Формат тот же, что пишет writeCodesCSV: заголовок "Символ,Код" и строки с
+// экранированным символом. Таблица, выгруженная один раз, позволяет передавать
+// только сжатые данные (формат formatExternalTable) и декодировать их на другой стороне.
+
+// обращает escapeSpecialChars
+func unescapeSpecialChars(s string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+1 == len(s) {
+			return "", fmt.Errorf("%q: обратная косая черта в конце строки", s)
+		}
+		i++
+		switch s[i] {
+		case '\\':
+			b.WriteByte('\\')
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case '"':
+			b.WriteByte('"')
+		case 'x':
+			if i+3 > len(s) {
+				return "", fmt.Errorf("%q: неполная последовательность \\x", s)
+			}
+			v, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+			if err != nil {
+				return "", fmt.Errorf("%q: неверная последовательность \\x%s", s, s[i+1:i+3])
+			}
+			b.WriteByte(byte(v))
+			i += 2
+		default:
+			return "", fmt.Errorf("%q: неизвестная последовательность \\%c", s, s[i])
+		}
+	}
+	return b.String(), nil
+}
+
+// читает таблицу кодов в формате writeCodesCSV
+func readCodesCSV(r io.Reader) (map[string]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 2
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("пустая таблица кодов")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if header[0] != "Символ" || header[1] != "Код" {
+		return nil, fmt.Errorf("неожиданный заголовок таблицы кодов: %q", header)
+	}
+
+	codes := make(map[string]string)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		symbol, err := unescapeSpecialChars(record[0])
+		if err != nil {
+			return nil, fmt.Errorf("строка %d: %v", line, err)
+		}
+		if _, exists := codes[symbol]; exists {
+			return nil, fmt.Errorf("строка %d: символ %q встречается повторно", line, record[0])
+		}
+		codes[symbol] = record[1]
+	}
+	return codes, nil
+}
+
+// загружает таблицу из CSV и проверяет, что по ней можно декодировать
+func loadCodesFromCSV(filename string) (map[string]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	codes, err := readCodesCSV(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	if report := validateCodeTable(codes, nil); !report.ok() {
+		return nil, fmt.Errorf("таблица %s некорректна: %s", filename, report)
+	}
+	return codes, nil
+}
+
+// кодирует файл готовой таблицей и пишет данные без таблицы (formatExternalTable)
+//
+// Символы файла, которых нет в таблице, пишутся через символ выхода, если он
+// в таблице есть. В строгом режиме (strict) любой такой символ — ошибка.
+func encodeFileWithTable(input, output string, codes map[string]string, read symbolReader, strict bool) error {
+	in, err := os.Open(input)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	alphabet, err := countFrequenciesStream(in, read)
+	if err != nil {
+		return err
+	}
+	var bitCount uint64
+	var missing []string
+	for _, s := range alphabet {
+		bits, ok := symbolBits(s.Char, codes)
+		if _, exists := codes[s.Char]; !ok || strict && !exists {
+			missing = append(missing, fmt.Sprintf("%q", s.Char))
+			continue
+		}
+		bitCount += uint64(s.Count) * bits
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("в таблице нет кодов для символов файла %s: %s", input, strings.Join(missing, ", "))
+	}
+	if _, err := in.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	out, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	w := bufio.NewWriter(out)
+	writeExternalTableHeader(w, bitCount)
+	if err := writeSymbols(in, w, codes, read, bitCount); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// восстанавливает файл по сжатым данным и отдельно загруженной таблице кодов
+func decodeFileWithTable(input, output string, codes map[string]string) error {
+	in, err := os.Open(input)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	r := bufio.NewReader(in)
+	bitCount, err := readExternalTableHeader(r)
+	if err != nil {
+		return err
+	}
+	root, err := buildDecodeTree(codes)
+	if err != nil {
+		return err
+	}
+
+	out, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	w := bufio.NewWriter(out)
+	if err := readEncodedText(newBitReader(r), root, bitCount, w); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return out.Close()
+}
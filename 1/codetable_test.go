@@ -0,0 +1,110 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCodesCSVRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	tables := map[string]map[string]string{
+		"пустая":      {},
+		"один символ": {"a": "0"},
+		"спецсимволы": {
+			"\\": "000", "\\n": "001", "\n": "010", "\t": "011",
+			"\"": "100", ",": "101", "\\x41": "110", literalEscapeSymbol: "111",
+		},
+	}
+	for name, input := range roundTripInputs() {
+		for _, unit := range sortedKeys(codingUnits) {
+			alphabet, err := countFrequenciesStream(strings.NewReader(input), codingUnits[unit])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(alphabet) > 0 {
+				tables[name+", "+unit] = generateCanonicalHuffmanCodes(alphabet)
+			}
+		}
+	}
+
+	for name, codes := range tables {
+		filename := filepath.Join(dir, "codes.csv")
+		if err := writeCodesToCSV(codes, filename); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		loaded, err := loadCodesFromCSV(filename)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(loaded, codes) {
+			t.Errorf("%s: загружено %q, ожидалось %q", name, loaded, codes)
+		}
+	}
+}
+
+func TestLoadCodesFromCSVErrors(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"пустой файл":            "",
+		"чужой заголовок":        "Char,Code\na,0\n",
+		"пустой код":             "Символ,Код\na,\n",
+		"одиночный пустой":       "Символ,Код\na,\nb,1\n",
+		"не двоичный код":        "Символ,Код\na,0\nb,12\n",
+		"префикс":                "Символ,Код\na,0\nb,01\n",
+		"повтор символа":         "Символ,Код\na,0\na,1\n",
+		"неверное экранирование": "Символ,Код\n\\q,0\nb,1\n",
+		"обрезанный \\x":         "Символ,Код\n\\xF,0\nb,1\n",
+	} {
+		filename := filepath.Join(dir, "codes.csv")
+		if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if codes, err := loadCodesFromCSV(filename); err == nil {
+			t.Errorf("%s: ожидалась ошибка, загружено %q", name, codes)
+		}
+	}
+}
+
+// данные, сжатые без таблицы, восстанавливаются по таблице, загруженной из CSV
+func TestEncodeFileWithTableRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "input")
+	table := filepath.Join(dir, "codes.csv")
+	encoded := filepath.Join(dir, "encoded.bin")
+	decoded := filepath.Join(dir, "decoded")
+	for name, input := range roundTripInputs() {
+		if err := os.WriteFile(source, []byte(input), 0644); err != nil {
+			t.Fatal(err)
+		}
+		for _, unit := range sortedKeys(codingUnits) {
+			read := codingUnits[unit]
+			alphabet, err := fileAlphabet(source, read)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := writeCodesToCSV(generateCanonicalHuffmanCodes(alphabet), table); err != nil {
+				t.Fatal(err)
+			}
+			codes, err := loadCodesFromCSV(table)
+			if err != nil {
+				t.Errorf("%s, %s: %v", name, unit, err)
+				continue
+			}
+			if err := encodeFileWithTable(source, encoded, codes, read, true); err != nil {
+				t.Errorf("%s, %s: кодирование: %v", name, unit, err)
+				continue
+			}
+			if err := decodeFileWithTable(encoded, decoded, codes); err != nil {
+				t.Errorf("%s, %s: декодирование: %v", name, unit, err)
+				continue
+			}
+			if got, _ := os.ReadFile(decoded); string(got) != input {
+				t.Errorf("%s, %s: восстановлено %q, ожидалось %q", name, unit, got, input)
+			}
+		}
+	}
+}
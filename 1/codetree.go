@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Дерево префиксного кода
+//
+// Путь от корня до листа — код символа: переход в Left дописывает 0, в Right — 1.
+// У внутреннего узла построенного дерева оба потомка; в дереве по неполной таблице
+// кодов (treeFromCodes) одного может не быть. Symbols хранит все символы поддерева,
+// Prob — их суммарную вероятность.
+type codeTree struct {
+	Symbols []Symbol
+	Prob    float64
+	Left    *codeTree
+	Right   *codeTree
+}
+
+func (t *codeTree) isLeaf() bool {
+	return t.Left == nil && t.Right == nil
+}
+
+// коды символов по дереву; единственный символ получает код "0", а не пустой —
+// иначе закодированный поток был бы пуст и декодер не восстановил бы ни одного символа
+func codesFromTree(root *codeTree) map[string]string {
+	codes := make(map[string]string)
+	var traverse func(node *codeTree, code string)
+	traverse = func(node *codeTree, code string) {
+		if node.isLeaf() {
+			if len(node.Symbols) == 1 {
+				codes[node.Symbols[0].Char] = code
+			}
+			return
+		}
+		traverse(node.Left, code+"0")
+		traverse(node.Right, code+"1")
+	}
+
+	switch {
+	case root == nil:
+	case root.isLeaf():
+		traverse(root, "0")
+	default:
+		traverse(root, "")
+	}
+	return codes
+}
+
+// дерево по готовой таблице кодов, чтобы рисунок совпадал с таблицей в CSV:
+// канонические коды Хаффмана имеют те же длины, что и дерево слияний, но другие пути
+func treeFromCodes(codes map[string]string, alphabet []Symbol) (*codeTree, error) {
+	if _, err := buildDecodeTree(codes); err != nil {
+		return nil, err
+	}
+	probs := make(map[string]Symbol, len(alphabet))
+	for _, s := range alphabet {
+		probs[s.Char] = s
+	}
+	symbols := sortedKeys(codes)
+	if len(symbols) == 1 {
+		// единственный символ с кодом "0" рисуется одним листом, как у buildHuffmanTree
+		return &codeTree{Symbols: []Symbol{probs[symbols[0]]}, Prob: probs[symbols[0]].Prob}, nil
+	}
+
+	root := &codeTree{}
+	for _, char := range symbols {
+		s, exists := probs[char]
+		if !exists {
+			s = Symbol{Char: char}
+		}
+		node := root
+		for _, bit := range codes[char] {
+			node.Symbols = append(node.Symbols, s)
+			node.Prob += s.Prob
+			next := &node.Left
+			if bit == '1' {
+				next = &node.Right
+			}
+			if *next == nil {
+				*next = &codeTree{}
+			}
+			node = *next
+		}
+		node.Symbols = []Symbol{s}
+		node.Prob = s.Prob
+	}
+	return root, nil
+}
+
+// Экспорт дерева в формат Graphviz DOT
+//
+// Узлы нумеруются в прямом порядке обхода, поэтому для одного дерева файл
+// всегда одинаков. Во внутренних узлах — вероятность поддерева, в листьях —
+// символ и его вероятность, на рёбрах — бит 0 или 1. SVG: dot -Tsvg tree.dot.
+func writeTreeDOT(w io.Writer, root *codeTree, name string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %s {\n", dotQuote(name))
+	b.WriteString("\tnode [shape=circle, fontname=\"Helvetica\"];\n")
+	b.WriteString("\tedge [fontname=\"Helvetica\"];\n")
+
+	id := 0
+	var visit func(node *codeTree) int
+	visit = func(node *codeTree) int {
+		self := id
+		id++
+		if node.isLeaf() {
+			label := fmt.Sprintf("«%s»\n%.4f", escapeSpecialChars(node.Symbols[0].Char), node.Prob)
+			fmt.Fprintf(&b, "\tn%d [shape=box, label=%s];\n", self, dotQuote(label))
+			return self
+		}
+		fmt.Fprintf(&b, "\tn%d [label=%s];\n", self, dotQuote(fmt.Sprintf("%.4f", node.Prob)))
+		for bit, child := range []*codeTree{node.Left, node.Right} {
+			if child == nil {
+				continue
+			}
+			childID := visit(child)
+			fmt.Fprintf(&b, "\tn%d -> n%d [label=\"%d\"];\n", self, childID, bit)
+		}
+		return self
+	}
+	if root != nil {
+		visit(root)
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// строка DOT в кавычках; перевод строки становится переносом в подписи
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+func writeTreeToDOT(root *codeTree, name, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := writeTreeDOT(file, root, name); err != nil {
+		return err
+	}
+	return file.Close()
+}
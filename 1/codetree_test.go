@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// путь в дереве совпадает с кодом из таблицы, а в корне — вся вероятность
+func TestTreeFromCodes(t *testing.T) {
+	for name, input := range roundTripInputs() {
+		if input == "" {
+			continue
+		}
+		alphabet := makeAlphabet(input)
+		for _, algorithm := range sortedKeys(codeAlgorithms) {
+			codes := codeAlgorithms[algorithm](alphabet)
+			root, err := treeFromCodes(codes, alphabet)
+			if err != nil {
+				t.Fatalf("%s, %s: %v", name, algorithm, err)
+			}
+			if got := codesFromTree(root); !reflect.DeepEqual(got, codes) {
+				t.Errorf("%s, %s: по дереву коды %q, в таблице %q", name, algorithm, got, codes)
+			}
+			if math.Abs(root.Prob-1) > 1e-9 {
+				t.Errorf("%s, %s: вероятность корня %v", name, algorithm, root.Prob)
+			}
+		}
+	}
+}
+
+func TestTreeFromCodesDOT(t *testing.T) {
+	// канонический Хаффман: «a» — 0, «b» — 10, «c» — 11
+	alphabet := makeAlphabet("aaaabbc")
+	root, err := treeFromCodes(generateCanonicalHuffmanCodes(alphabet), alphabet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := writeTreeDOT(&b, root, "huffman"); err != nil {
+		t.Fatal(err)
+	}
+	for _, edge := range []string{`n0 -> n1 [label="0"]`, `n0 -> n2 [label="1"]`, `n2 -> n3 [label="0"]`, `n2 -> n4 [label="1"]`} {
+		if !strings.Contains(b.String(), edge) {
+			t.Errorf("нет ребра %s в\n%s", edge, b.String())
+		}
+	}
+	if !strings.Contains(b.String(), "«a»") {
+		t.Errorf("нет листа «a» в\n%s", b.String())
+	}
+
+	if _, err := treeFromCodes(map[string]string{"a": "0", "b": "01"}, nil); err == nil {
+		t.Error("код-префикс: ожидалась ошибка")
+	}
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Формат сжатого файла:
+//
+//	"DMH1"                      сигнатура
+//	1 байт                      формат таблицы кодов
+//	uvarint                     количество символов
+//	для каждого символа (formatExplicitCodes):
+//	  uvarint + байты           символ в UTF-8
+//	  uvarint + упакованные биты длина кода и сам код
+//	для каждого символа (formatCanonicalLengths), в каноническом порядке:
+//	  uvarint + байты           символ в UTF-8
+//	  uvarint                   длина кода
+//	uvarint                     точное количество бит данных
+//	упакованные биты данных, последний байт дополнен нулями
+//
+// В формате formatExternalTable таблицы в файле нет: сразу после байта формата
+// идёт количество бит, а таблицу декодер загружает из CSV (см. loadCodesFromCSV).
+const containerMagic = "DMH1"
+
+const (
+	formatExplicitCodes    byte = 1
+	formatCanonicalLengths byte = 2 // коды восстанавливаются функцией canonicalCodes
+	formatExternalTable    byte = 3
+)
+
+// Ограничения заголовка: значения из файла проверяются до выделения памяти,
+// поэтому испорченный заголовок даёт ошибку, а не панику.
+const (
+	// canonicalCodes собирает код в uint64, поэтому длиннее 64 бит он быть не может
+	maxCanonicalCodeLength = 64
+	// самый длинный символ — биграмма из двух знаков UTF-8
+	maxSymbolLength = 2 * utf8.UTFMax
+	maxSymbols      = 1 << 20
+)
+
+var errExternalTable = errors.New("таблица кодов хранится отдельно: для декодирования нужен CSV с таблицей")
+
+// узел дерева декодирования, построенного по таблице кодов
+type decodeNode struct {
+	child  [2]*decodeNode
+	symbol string
+	leaf   bool
+}
+
+// строит префиксное дерево по таблице кодов, проверяя что ни один код не является префиксом другого
+func buildDecodeTree(codes map[string]string) (*decodeNode, error) {
+	root := &decodeNode{}
+	for symbol, code := range codes {
+		if code == "" {
+			return nil, fmt.Errorf("пустой код у символа %q", symbol)
+		}
+		node := root
+		for i := 0; i < len(code); i++ {
+			if node.leaf {
+				return nil, fmt.Errorf("код символа %q продолжает чужой код", symbol)
+			}
+			bit := code[i] - '0'
+			if bit > 1 {
+				return nil, fmt.Errorf("недопустимый код %q у символа %q", code, symbol)
+			}
+			if node.child[bit] == nil {
+				node.child[bit] = &decodeNode{}
+			}
+			node = node.child[bit]
+		}
+		if node.leaf || node.child[0] != nil || node.child[1] != nil {
+			return nil, fmt.Errorf("код символа %q является префиксом другого кода", symbol)
+		}
+		node.leaf = true
+		node.symbol = symbol
+	}
+	return root, nil
+}
+
+func writeContainerHeader(w *bufio.Writer, codes map[string]string, bitCount uint64) error {
+	w.WriteString(containerMagic)
+	// для канонической таблицы хватает длин кодов
+	if isCanonical(codes) {
+		w.WriteByte(formatCanonicalLengths)
+		writeUvarint(w, uint64(len(codes)))
+		for _, symbol := range canonicalOrder(codeLengths(codes)) {
+			writeUvarint(w, uint64(len(symbol)))
+			w.WriteString(symbol)
+			writeUvarint(w, uint64(len(codes[symbol])))
+		}
+		writeUvarint(w, bitCount)
+		return nil
+	}
+
+	w.WriteByte(formatExplicitCodes)
+	writeUvarint(w, uint64(len(codes)))
+
+	symbols := make([]string, 0, len(codes))
+	for symbol := range codes {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+
+	for _, symbol := range symbols {
+		code := codes[symbol]
+		writeUvarint(w, uint64(len(symbol)))
+		w.WriteString(symbol)
+		writeUvarint(w, uint64(len(code)))
+		bw := newBitWriter(w)
+		bw.writeCode(code)
+		if err := bw.flush(); err != nil {
+			return err
+		}
+	}
+
+	writeUvarint(w, bitCount)
+	return nil
+}
+
+// заголовок без таблицы: сигнатура, formatExternalTable и количество бит
+func writeExternalTableHeader(w *bufio.Writer, bitCount uint64) {
+	w.WriteString(containerMagic)
+	w.WriteByte(formatExternalTable)
+	writeUvarint(w, bitCount)
+}
+
+func readExternalTableHeader(r *bufio.Reader) (uint64, error) {
+	format, err := readContainerFormat(r)
+	if err != nil {
+		return 0, err
+	}
+	if format != formatExternalTable {
+		return 0, errors.New("в файле своя таблица кодов, внешняя не нужна")
+	}
+	return binary.ReadUvarint(r)
+}
+
+func readContainerFormat(r *bufio.Reader) (byte, error) {
+	magic := make([]byte, len(containerMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != containerMagic {
+		return 0, errors.New("файл не является сжатым контейнером")
+	}
+	format, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if format != formatExplicitCodes && format != formatCanonicalLengths && format != formatExternalTable {
+		return 0, fmt.Errorf("неизвестный формат таблицы кодов: %d", format)
+	}
+	return format, nil
+}
+
+func readContainerHeader(r *bufio.Reader) (map[string]string, uint64, error) {
+	format, err := readContainerFormat(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	if format == formatExternalTable {
+		return nil, 0, errExternalTable
+	}
+
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	if count > maxSymbols {
+		return nil, 0, fmt.Errorf("в таблице %d символов, допустимо не больше %d", count, maxSymbols)
+	}
+	codes := make(map[string]string)
+	lengths := make(map[string]int)
+	for i := uint64(0); i < count; i++ {
+		symbolLen, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, 0, err
+		}
+		if symbolLen > maxSymbolLength {
+			return nil, 0, fmt.Errorf("символ длиной %d байт, допустимо не больше %d", symbolLen, maxSymbolLength)
+		}
+		symbol := make([]byte, symbolLen)
+		if _, err := io.ReadFull(r, symbol); err != nil {
+			return nil, 0, err
+		}
+		codeLen, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, 0, err
+		}
+		if format == formatCanonicalLengths {
+			if codeLen == 0 || codeLen > maxCanonicalCodeLength {
+				return nil, 0, fmt.Errorf("символ %q: недопустимая длина кода %d", symbol, codeLen)
+			}
+			lengths[string(symbol)] = int(codeLen)
+			continue
+		}
+		br := newBitReader(r)
+		var code strings.Builder
+		for j := uint64(0); j < codeLen; j++ {
+			bit, err := br.readBit()
+			if err != nil {
+				return nil, 0, err
+			}
+			code.WriteByte('0' + byte(bit))
+		}
+		codes[string(symbol)] = code.String()
+	}
+	if format == formatCanonicalLengths {
+		codes = canonicalCodes(lengths)
+	}
+	if report := validateCodeTable(codes, nil); !report.ok() {
+		return nil, 0, fmt.Errorf("таблица кодов в файле некорректна: %s", report)
+	}
+
+	bitCount, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	return codes, bitCount, nil
+}
+
+func writeUvarint(w *bufio.Writer, v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	w.Write(buf[:n])
+}
+
+// кодирует текст и записывает его вместе с таблицей кодов в один двоичный файл
+func encodeToFile(text string, codes map[string]string, filename string) error {
+	var bitCount uint64
+	for i := 0; i < len(text); {
+		symbol := textSymbolAt(text, i)
+		i += len(symbol)
+		bits, ok := symbolBits(symbol, codes)
+		if !ok {
+			return fmt.Errorf("символа %q нет в таблице, а символа выхода нет", symbol)
+		}
+		bitCount += bits
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	if err := writeContainerHeader(w, codes, bitCount); err != nil {
+		return err
+	}
+	bw := newBitWriter(w)
+	if err := writeEncodedText(bw, text, codes); err != nil {
+		return err
+	}
+	if err := bw.flush(); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// символы без кода пишутся через выход; если выхода нет — ошибка
+func writeEncodedText(bw *bitWriter, text string, codes map[string]string) error {
+	for i := 0; i < len(text); {
+		symbol := textSymbolAt(text, i)
+		i += len(symbol)
+		if err := writeSymbolWithEscape(bw, symbol, codes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// восстанавливает текст только по содержимому сжатого файла
+func decodeFromFile(filename string) (string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+	codes, bitCount, err := readContainerHeader(r)
+	if err != nil {
+		return "", err
+	}
+	root, err := buildDecodeTree(codes)
+	if err != nil {
+		return "", err
+	}
+
+	var decoded strings.Builder
+	if err := readEncodedText(newBitReader(r), root, bitCount, &decoded); err != nil {
+		return "", err
+	}
+	return decoded.String(), nil
+}
+
+var (
+	errUnknownCode = errors.New("в потоке встретилась последовательность бит без кода")
+	errTruncated   = errors.New("поток оборвался посреди кода")
+	// код выхода биграмм допустим только в decodeBigramText
+	errBigramEscape = errors.New("в посимвольном потоке встретился код выхода биграмм")
+)
+
+// декодирует ровно bitCount бит, проходя по дереву от корня до листа, и пишет символы в w;
+// после кода выхода читается литерал
+func readEncodedText(br *bitReader, root *decodeNode, bitCount uint64, w io.StringWriter) error {
+	node := root
+	for i := uint64(0); i < bitCount; i++ {
+		bit, err := br.readBit()
+		if err != nil {
+			return err
+		}
+		node = node.child[bit]
+		if node == nil {
+			return errUnknownCode
+		}
+		if !node.leaf {
+			continue
+		}
+		symbol := node.symbol
+		if symbol == escapeSymbol {
+			return errBigramEscape
+		}
+		if symbol == literalEscapeSymbol {
+			if symbol, err = readPackedLiteral(br); err != nil {
+				return err
+			}
+			i += uint64(8 * (1 + len(symbol)))
+			if i >= bitCount {
+				return errTruncated
+			}
+		}
+		if _, err := w.WriteString(symbol); err != nil {
+			return err
+		}
+		node = root
+	}
+	if node != root {
+		return errTruncated
+	}
+	return nil
+}
+
+// размер заголовка с таблицей кодов в байтах — цена передачи таблицы декодеру
+func headerSize(codes map[string]string) int {
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	writeContainerHeader(w, codes, 0)
+	w.Flush()
+	return buf.Len()
+}
+
+func fileSize(filename string) int64 {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
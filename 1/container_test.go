@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// входные данные, на которых проверяется восстановление байт в байт
+func roundTripInputs() map[string]string {
+	all := make([]byte, 256)
+	for i := range all {
+		all[i] = byte(i)
+	}
+	return map[string]string{
+		"пустой":             "",
+		"один символ":        "я",
+		"повтор символа":     "ааааааааааа",
+		"недопустимый UTF-8": "ok\xff\xfeпри\xd0\xbf\xd1\x80\xc3 \xed\xa0\x80\xff\xff\xff",
+		"все 256 байт":       string(all),
+		"текст":              "абракадабра\nи ещё раз абракадабра\r\n\t\\n\"",
+		// буфер bufio.Reader — 4096 байт: «я» разрезана границей первого блока,
+		// дальше многобайтовый символ и недопустимый байт стоят у границ следующих
+		"символ на границе буфера": strings.Repeat("a", 4095) + "я" + strings.Repeat("b", 4094) + "€" +
+			strings.Repeat("c", 4093) + "\xd0" + "ё",
+	}
+}
+
+func TestEncodeFileRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	for name, input := range roundTripInputs() {
+		source := filepath.Join(dir, "input")
+		if err := os.WriteFile(source, []byte(input), 0644); err != nil {
+			t.Fatal(err)
+		}
+		for _, unit := range sortedKeys(codingUnits) {
+			for _, algorithm := range sortedKeys(codeAlgorithms) {
+				encoded := filepath.Join(dir, "encoded.bin")
+				decoded := filepath.Join(dir, "decoded")
+				if _, err := encodeFile(source, encoded, codeAlgorithms[algorithm], codingUnits[unit]); err != nil {
+					t.Errorf("%s, %s, %s: кодирование: %v", name, unit, algorithm, err)
+					continue
+				}
+				if err := decodeFile(encoded, decoded); err != nil {
+					t.Errorf("%s, %s, %s: декодирование: %v", name, unit, algorithm, err)
+					continue
+				}
+				if got, _ := os.ReadFile(decoded); string(got) != input {
+					t.Errorf("%s, %s, %s: восстановлено %q, ожидалось %q", name, unit, algorithm, got, input)
+				}
+			}
+		}
+	}
+}
+
+func TestEncodeToFileRoundTrip(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "encoded.bin")
+	for name, input := range roundTripInputs() {
+		for _, algorithm := range sortedKeys(codeAlgorithms) {
+			codes := codeAlgorithms[algorithm](makeAlphabet(input))
+			if err := encodeToFile(input, codes, filename); err != nil {
+				t.Errorf("%s, %s: кодирование: %v", name, algorithm, err)
+				continue
+			}
+			decoded, err := decodeFromFile(filename)
+			if err != nil {
+				t.Errorf("%s, %s: декодирование: %v", name, algorithm, err)
+				continue
+			}
+			if decoded != input {
+				t.Errorf("%s, %s: восстановлено %q, ожидалось %q", name, algorithm, decoded, input)
+			}
+		}
+	}
+}
+
+// символы, которых нет в таблице, проходят через выход; без выхода и в строгом режиме — ошибка
+func TestEscapeRoundTrip(t *testing.T) {
+	const train, test = "абв", "абв где ☃ 𝄞 a"
+	filename := filepath.Join(t.TempDir(), "encoded.bin")
+	for _, algorithm := range sortedKeys(codeAlgorithms) {
+		plain := codeAlgorithms[algorithm](makeAlphabet(train))
+		if err := encodeToFile(test, plain, filename); err == nil {
+			t.Errorf("%s: без символа выхода ожидалась ошибка", algorithm)
+		}
+
+		codes := withEscape(codeAlgorithms[algorithm])(makeAlphabet(train))
+		if _, err := encodeText(test, codes, true); err == nil {
+			t.Errorf("%s: в строгом режиме ожидалась ошибка", algorithm)
+		}
+		encoded, err := encodeText(test, codes, false)
+		if err != nil {
+			t.Fatalf("%s: %v", algorithm, err)
+		}
+		if decoded, err := decodeText(encoded, codes); err != nil || decoded != test {
+			t.Errorf("%s: decodeText вернул %q, %v", algorithm, decoded, err)
+		}
+		if _, err := decodeText(encoded[:len(encoded)-1], codes); err == nil {
+			t.Errorf("%s: обрезанный поток декодирован без ошибки", algorithm)
+		}
+
+		if err := encodeToFile(test, codes, filename); err != nil {
+			t.Fatalf("%s: %v", algorithm, err)
+		}
+		if decoded, err := decodeFromFile(filename); err != nil || decoded != test {
+			t.Errorf("%s: decodeFromFile вернул %q, %v", algorithm, decoded, err)
+		}
+
+		// литерал хранит длину, поэтому через выход проходит и недопустимый байт
+		if err := encodeToFile("аб\xff", codes, filename); err != nil {
+			t.Fatalf("%s: %v", algorithm, err)
+		}
+		if decoded, err := decodeFromFile(filename); err != nil || decoded != "аб\xff" {
+			t.Errorf("%s: decodeFromFile вернул %q, %v", algorithm, decoded, err)
+		}
+	}
+}
+
+// таблица с выходом, построенная по одному файлу, кодирует любые символы другого
+// файла в каждой единице кодирования
+func TestEscapeTableRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	train := filepath.Join(dir, "train")
+	source := filepath.Join(dir, "input")
+	encoded := filepath.Join(dir, "encoded.bin")
+	decoded := filepath.Join(dir, "decoded")
+	if err := os.WriteFile(train, []byte("abc"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	const input = "abc\x80\xff где abd"
+	if err := os.WriteFile(source, []byte(input), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, unit := range sortedKeys(codingUnits) {
+		read := codingUnits[unit]
+		alphabet, err := fileAlphabet(train, read)
+		if err != nil {
+			t.Fatal(err)
+		}
+		codes := withEscape(generateCanonicalHuffmanCodes)(alphabet)
+		if err := encodeFileWithTable(source, encoded, codes, read, false); err != nil {
+			t.Errorf("%s: кодирование: %v", unit, err)
+			continue
+		}
+		if err := decodeFileWithTable(encoded, decoded, codes); err != nil {
+			t.Errorf("%s: декодирование: %v", unit, err)
+			continue
+		}
+		if got, _ := os.ReadFile(decoded); string(got) != input {
+			t.Errorf("%s: восстановлено %q, ожидалось %q", unit, got, input)
+		}
+	}
+}
+
+// заголовок формата formatCanonicalLengths с двумя символами "a" и "b"
+func canonicalHeader(lengthA, lengthB uint64) []byte {
+	var b bytes.Buffer
+	b.WriteString(containerMagic)
+	b.WriteByte(formatCanonicalLengths)
+	b.Write(binary.AppendUvarint(nil, 2))
+	for _, entry := range []struct {
+		symbol string
+		length uint64
+	}{{"a", lengthA}, {"b", lengthB}} {
+		b.Write(binary.AppendUvarint(nil, uint64(len(entry.symbol))))
+		b.WriteString(entry.symbol)
+		b.Write(binary.AppendUvarint(nil, entry.length))
+	}
+	b.Write(binary.AppendUvarint(nil, 0))
+	return b.Bytes()
+}
+
+func TestCanonicalHeaderLengths(t *testing.T) {
+	if err := decodeStream(bytes.NewReader(canonicalHeader(1, 1)), io.Discard); err != nil {
+		t.Fatalf("корректный заголовок: %v", err)
+	}
+	for _, length := range []uint64{0, maxCanonicalCodeLength + 1, 1 << 40, 1<<64 - 1} {
+		if err := decodeStream(bytes.NewReader(canonicalHeader(length, 1)), io.Discard); err == nil {
+			t.Errorf("длина кода %d: ожидалась ошибка", length)
+		}
+	}
+}
+
+// испорченный контейнер декодируется с ошибкой или в другой текст, но без паники
+func TestCorruptedContainer(t *testing.T) {
+	text := "абракадабра, и ещё раз абракадабра\n"
+	alphabet := makeAlphabet(text)
+	rng := rand.New(rand.NewSource(1))
+	for _, algorithm := range sortedKeys(codeAlgorithms) {
+		var encoded bytes.Buffer
+		read := codingUnits["char"]
+		if err := encodeStream(bytes.NewReader([]byte(text)), &encoded, alphabet, codeAlgorithms[algorithm](alphabet), read); err != nil {
+			t.Fatal(err)
+		}
+		original := encoded.Bytes()
+		for trial := 0; trial < 5000; trial++ {
+			data := append([]byte{}, original...)
+			for flips := 1 + rng.Intn(3); flips > 0; flips-- {
+				data[rng.Intn(len(data))] = byte(rng.Intn(256))
+			}
+			decodeStream(bytes.NewReader(data), io.Discard)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"unicode/utf8"
+)
+
+// Код, построенный на одном тексте, применённый к другому
+//
+// Q — распределение символов тестового текста, P — обучающего (оба из makeAlphabet).
+// Платой за несовпадение моделей служит расхождение Кульбака-Лейблера
+// D(Q||P) = H(Q,P) - H(Q): столько бит на символ теряет даже идеальный код для P.
+// Символов теста, которых не было в обучающем тексте, P не предсказывает вовсе,
+// тогда H(Q,P) и D(Q||P) бесконечны, а в коде для них предусмотрен символ выхода.
+type crossCorpusReport struct {
+	TestEntropy   float64 // H(Q)
+	CrossEntropy  float64 // H(Q,P) = -Σ q log2 p
+	KLDivergence  float64 // D(Q||P) = Σ q log2(q/p)
+	UnseenSymbols int     // различных символов теста нет в обучающем алфавите
+	UnseenShare   float64 // их доля среди символов теста
+	// H(Q,P) и D(Q||P) для Q, ограниченного символами обучающего алфавита;
+	// конечны и тогда, когда полные величины бесконечны
+	SeenCrossEntropy float64
+	SeenKLDivergence float64
+	Coders           []crossCoderResult
+}
+
+// фактическая длина кода обучающей модели на тестовом тексте
+type crossCoderResult struct {
+	ID, Name      string
+	AverageLength float64 // бит на символ теста, вместе с выходами и литералами
+	TrainLength   float64 // средняя длина того же кода на обучающем тексте
+}
+
+func crossCorpus(train, test string) (crossCorpusReport, error) {
+	trainAlphabet := makeAlphabet(train)
+	testAlphabet := makeAlphabet(test)
+	p := make(map[string]float64, len(trainAlphabet))
+	for _, s := range trainAlphabet {
+		p[s.Char] = s.Prob
+	}
+
+	report := crossCorpusReport{TestEntropy: calculateEntropy(testAlphabet)}
+	for _, q := range testAlphabet {
+		pp, exists := p[q.Char]
+		if !exists {
+			report.UnseenSymbols++
+			report.UnseenShare += q.Prob
+			continue
+		}
+		report.CrossEntropy -= q.Prob * math.Log2(pp)
+		report.KLDivergence += q.Prob * math.Log2(q.Prob/pp)
+	}
+	report.SeenCrossEntropy, report.SeenKLDivergence = report.CrossEntropy, report.KLDivergence
+	if report.UnseenSymbols > 0 {
+		seen := 1 - report.UnseenShare
+		report.SeenCrossEntropy /= seen
+		// Σ (q/seen) log2(q/(seen p)) = (Σ q log2(q/p)) / seen - log2 seen
+		report.SeenKLDivergence = report.KLDivergence/seen - math.Log2(seen)
+		report.CrossEntropy = math.Inf(1)
+		report.KLDivergence = math.Inf(1)
+	}
+
+	// символ выхода получает минимальный вес, чтобы почти не удлинять остальные коды
+	codingAlphabet := withEscapeSymbol(trainAlphabet, literalEscapeSymbol, 1)
+	testLength := utf8.RuneCountInString(test)
+	for _, m := range codeMethods(codingAlphabet) {
+		encoded, err := encodeText(test, m.Codes, false)
+		if err != nil {
+			return crossCorpusReport{}, fmt.Errorf("%s: %v", m.Name, err)
+		}
+		decoded, err := decodeText(encoded, m.Codes)
+		if err != nil {
+			return crossCorpusReport{}, fmt.Errorf("%s: %v", m.Name, err)
+		}
+		if decoded != test {
+			return crossCorpusReport{}, fmt.Errorf("%s: декодированный тест не совпадает с исходным", m.Name)
+		}
+		report.Coders = append(report.Coders, crossCoderResult{
+			ID:            m.ID,
+			Name:          m.Name,
+			AverageLength: float64(len(encoded)) / float64(testLength),
+			TrainLength:   calculateAverageCodeLength(trainAlphabet, m.Codes),
+		})
+	}
+	return report, nil
+}
+
+func printCrossCorpusReport(w io.Writer, report crossCorpusReport) {
+	fmt.Fprintf(w, "  Энтропия теста H(Q): %.4f бит/символ\n", report.TestEntropy)
+	fmt.Fprintf(w, "  Перекрёстная энтропия H(Q,P): %.4f бит/символ\n", report.CrossEntropy)
+	fmt.Fprintf(w, "  Расхождение D(Q||P): %.4f бит/символ\n", report.KLDivergence)
+	if report.UnseenSymbols > 0 {
+		fmt.Fprintf(w, "  Символов теста нет в обучающем тексте: %d (%.4f%% текста)\n",
+			report.UnseenSymbols, report.UnseenShare*100)
+		fmt.Fprintf(w, "  По символам обучающего алфавита: H(Q,P) %.4f, D(Q||P) %.4f бит/символ\n",
+			report.SeenCrossEntropy, report.SeenKLDivergence)
+	}
+	fmt.Fprintf(w, "  %-22s %14s %14s\n", "Метод", "На обучающем", "На тесте")
+	for _, c := range report.Coders {
+		fmt.Fprintf(w, "  %-22s %14.4f %14.4f\n", c.Name, c.TrainLength, c.AverageLength)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Кодирование символов, которых нет в таблице
+//
+// Если в таблице есть literalEscapeSymbol, неизвестный символ записывается кодом
+// выхода и сразу за ним — литералом: длиной символа в байтах (8 бит) и самими
+// байтами по 8 бит. Через выход проходит любой символ любой единицы кодирования:
+// знак UTF-8, недопустимый байт, байт побайтового алфавита, биграмма. Без символа
+// выхода неизвестный символ — ошибка: данные никогда не теряются молча.
+
+// Символ выхода перед литералом. Это не escapeSymbol биграмм: за тем следует
+// код из таблицы одиночных символов. Три байта 0xFF не совпадают ни с байтом, ни
+// со знаком UTF-8, ни с парой из знаков и недопустимых байтов, то есть ни с одним
+// символом readByteSymbol, readRuneSymbol и readBigramSymbol; в CSV — \xFF\xFF\xFF.
+const literalEscapeSymbol = "\xff\xff\xff"
+
+// наибольшая длина литерала в байтах: длина пишется восемью битами
+const maxLiteralLength = 255
+
+// добавляет к любому построителю кода символ выхода с минимальным весом
+func withEscape(generate func([]Symbol) map[string]string) func([]Symbol) map[string]string {
+	return func(alphabet []Symbol) map[string]string {
+		return generate(withEscapeSymbol(alphabet, literalEscapeSymbol, 1))
+	}
+}
+
+// пишет символ его кодом, а символ без кода — кодом выхода и литералом
+func writeSymbolWithEscape(bw *bitWriter, symbol string, codes map[string]string) error {
+	if code, exists := codes[symbol]; exists {
+		bw.writeCode(code)
+		return nil
+	}
+	escape, exists := codes[literalEscapeSymbol]
+	if !exists {
+		return fmt.Errorf("символа %q нет в таблице, а символа выхода нет", symbol)
+	}
+	bw.writeCode(escape)
+	return writeLiteral(bw, symbol)
+}
+
+// число бит, которое займёт символ, с учётом выхода; false — символ закодировать нельзя
+func symbolBits(symbol string, codes map[string]string) (uint64, bool) {
+	if code, exists := codes[symbol]; exists {
+		return uint64(len(code)), true
+	}
+	escape, exists := codes[literalEscapeSymbol]
+	if !exists || len(symbol) == 0 || len(symbol) > maxLiteralLength {
+		return 0, false
+	}
+	return uint64(len(escape) + 8*(1+len(symbol))), true
+}
+
+// пишет литерал: длину в байтах и сами байты
+func writeLiteral(bw *bitWriter, symbol string) error {
+	if len(symbol) == 0 || len(symbol) > maxLiteralLength {
+		return fmt.Errorf("символ %q нельзя записать литералом: длина %d байт", symbol, len(symbol))
+	}
+	bw.writeBits(uint64(len(symbol)), 8)
+	for i := 0; i < len(symbol); i++ {
+		bw.writeBits(uint64(symbol[i]), 8)
+	}
+	return nil
+}
+
+var errInvalidLiteral = errors.New("в потоке записан литерал нулевой длины")
+
+// читает литерал; next возвращает очередные 8 бит
+func readLiteral(next func() (byte, error)) (string, error) {
+	n, err := next()
+	if err != nil {
+		return "", err
+	}
+	if n == 0 {
+		return "", errInvalidLiteral
+	}
+	literal := make([]byte, n)
+	for i := range literal {
+		if literal[i], err = next(); err != nil {
+			return "", err
+		}
+	}
+	return string(literal), nil
+}
+
+// читает литерал из упакованного потока
+func readPackedLiteral(br *bitReader) (string, error) {
+	return readLiteral(func() (byte, error) {
+		b, err := br.readBits(8)
+		return byte(b), err
+	})
+}
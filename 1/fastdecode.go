@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+)
+
+// Табличный декодер префиксных кодов
+//
+// Вместо спуска по дереву на каждый бит декодер смотрит сразу на следующие
+// k бит упакованного потока и по таблице из 2^k записей узнаёт символ и длину
+// его кода. Коды длиннее k бит в таблицу не помещаются: для них запись пустая,
+// и символ дочитывается по дереву.
+const fastLookupBits = 10
+
+type lookupEntry struct {
+	symbol int32 // номер в fastDecoder.symbols
+	length uint8 // длина кода, 0 — код длиннее k бит
+}
+
+type fastDecoder struct {
+	symbols []string
+	table   []lookupEntry
+	root    *decodeNode
+	k       uint
+}
+
+func newFastDecoder(codes map[string]string, k uint) (*fastDecoder, error) {
+	for _, escape := range []string{escapeSymbol, literalEscapeSymbol} {
+		if _, exists := codes[escape]; exists {
+			return nil, errors.New("табличный декодер не поддерживает символ выхода")
+		}
+	}
+	root, err := buildDecodeTree(codes)
+	if err != nil {
+		return nil, err
+	}
+	d := &fastDecoder{
+		table: make([]lookupEntry, 1<<k),
+		root:  root,
+		k:     k,
+	}
+	for symbol, code := range codes {
+		if uint(len(code)) > k {
+			continue
+		}
+		index := int32(len(d.symbols))
+		d.symbols = append(d.symbols, symbol)
+		// код занимает все ячейки, у которых он является префиксом
+		prefix := 0
+		for i := 0; i < len(code); i++ {
+			prefix = prefix<<1 | int(code[i]-'0')
+		}
+		shift := k - uint(len(code))
+		for tail := 0; tail < 1<<shift; tail++ {
+			d.table[prefix<<shift|tail] = lookupEntry{symbol: index, length: uint8(len(code))}
+		}
+	}
+	return d, nil
+}
+
+// декодирует ровно bitCount бит упакованного потока
+func (d *fastDecoder) decode(data []byte, bitCount uint64) (string, error) {
+	var decoded strings.Builder
+	decoded.Grow(len(data) * 2)
+
+	var acc uint64 // непрочитанные биты в младших разрядах
+	nbits := uint(0)
+	pos := 0
+	remaining := bitCount
+	refill := func() {
+		for nbits <= 56 && pos < len(data) {
+			acc = acc<<8 | uint64(data[pos])
+			pos++
+			nbits += 8
+		}
+	}
+	// следующие n бит; если в буфере меньше, недостающие считаются нулями
+	peek := func(n uint) uint64 {
+		if nbits >= n {
+			return acc >> (nbits - n) & (1<<n - 1)
+		}
+		return acc << (n - nbits) & (1<<n - 1)
+	}
+
+	for remaining > 0 {
+		refill()
+		entry := d.table[peek(d.k)]
+		if entry.length > 0 && uint64(entry.length) <= remaining {
+			decoded.WriteString(d.symbols[entry.symbol])
+			nbits -= uint(entry.length)
+			remaining -= uint64(entry.length)
+			continue
+		}
+
+		// длинный код: спуск по дереву
+		node := d.root
+		for !node.leaf {
+			if remaining == 0 {
+				return "", errTruncated
+			}
+			if nbits == 0 {
+				refill()
+			}
+			node = node.child[peek(1)]
+			nbits--
+			remaining--
+			if node == nil {
+				return "", errUnknownCode
+			}
+		}
+		decoded.WriteString(node.symbol)
+	}
+	return decoded.String(), nil
+}
+
+// упаковывает текст в биты; возвращает данные и точное число бит
+func packText(text string, codes map[string]string) ([]byte, uint64, error) {
+	var buf bytes.Buffer
+	bw := newBitWriter(&buf)
+	if err := writeEncodedText(bw, text, codes); err != nil {
+		return nil, 0, err
+	}
+	bitCount := bw.count
+	if err := bw.flush(); err != nil {
+		return nil, 0, err
+	}
+	return buf.Bytes(), bitCount, nil
+}
+
+// проверяет, что табличный декодер восстанавливает текст без искажений;
+// скорость декодеров сравнивают бенчмарки: go test -bench Decode
+func checkFastDecoder(text string, codes map[string]string, k uint) error {
+	packed, bitCount, err := packText(text, codes)
+	if err != nil {
+		return err
+	}
+	fast, err := newFastDecoder(codes, k)
+	if err != nil {
+		return err
+	}
+	decoded, err := fast.decode(packed, bitCount)
+	if err != nil {
+		return err
+	}
+	if decoded != text {
+		return errors.New("табличный декодер вернул неверный текст")
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+// текст лабораторной и его коды Хаффмана для бенчмарков декодеров
+func benchmarkInput(b *testing.B) (string, map[string]string) {
+	b.Helper()
+	content, err := os.ReadFile("text.txt")
+	if err != nil {
+		b.Skip("нет text.txt:", err)
+	}
+	text := string(content)
+	return text, generateCanonicalHuffmanCodes(makeAlphabet(text))
+}
+
+func BenchmarkDecodeText(b *testing.B) {
+	text, codes := benchmarkInput(b)
+	encoded, err := encodeText(text, codes, true)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(text)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := decodeText(encoded, codes); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTreeDecode(b *testing.B) {
+	text, codes := benchmarkInput(b)
+	packed, bitCount, err := packText(text, codes)
+	if err != nil {
+		b.Fatal(err)
+	}
+	root, err := buildDecodeTree(codes)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(text)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var decoded strings.Builder
+		if err := readEncodedText(newBitReader(bytes.NewReader(packed)), root, bitCount, &decoded); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTableDecode(b *testing.B) {
+	text, codes := benchmarkInput(b)
+	packed, bitCount, err := packText(text, codes)
+	if err != nil {
+		b.Fatal(err)
+	}
+	fast, err := newFastDecoder(codes, fastLookupBits)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(text)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := fast.decode(packed, bitCount); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Частоты Фибоначчи дают самое глубокое дерево Хаффмана: коды до 19 бит,
+// то есть длиннее fastLookupBits, и декодер дочитывает их по дереву.
+func TestFastDecoderLongCodes(t *testing.T) {
+	a, b := 1, 1
+	var text strings.Builder
+	for i := 0; i < 20; i++ {
+		symbol := string(rune('a' + i))
+		text.WriteString(strings.Repeat(symbol, a))
+		a, b = b, a+b
+	}
+	codes := generateCanonicalHuffmanCodes(makeAlphabet(text.String()))
+
+	longest := 0
+	for _, code := range codes {
+		longest = max(longest, len(code))
+	}
+	if longest <= fastLookupBits {
+		t.Fatalf("самый длинный код %d бит, нужен длиннее %d", longest, fastLookupBits)
+	}
+
+	for _, k := range []uint{1, 4, fastLookupBits} {
+		if err := checkFastDecoder(text.String(), codes, k); err != nil {
+			t.Errorf("k=%d: %v", k, err)
+		}
+	}
+}
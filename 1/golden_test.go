@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Эталонные таблицы кодов
+//
+// Для фиксированного текста testdata/golden_input.txt таблицы кодов Шеннона-Фано
+// и Хаффмана записаны в testdata в том же формате, что и huffman_codes.csv и
+// shannon_fano_codes.csv. Тест строит таблицы заново (дважды, чтобы поймать
+// зависимость от порядка обхода map) и сравнивает их с эталоном побайтово.
+// После намеренного изменения кодов эталон обновляется: go test -run TestGoldenTables -update
+var update = flag.Bool("update", false, "перезаписать эталонные таблицы в testdata")
+
+const goldenInput = "testdata/golden_input.txt"
+
+func goldenTables(text string) map[string]map[string]string {
+	alphabet := makeAlphabet(text)
+	return map[string]map[string]string{
+		"shannon_fano_codes.csv": generateShannonFanoCodes(alphabet),
+		"huffman_codes.csv":      generateCanonicalHuffmanCodes(alphabet),
+	}
+}
+
+func TestGoldenTables(t *testing.T) {
+	content, err := os.ReadFile(goldenInput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := string(content)
+	first, second := goldenTables(text), goldenTables(text)
+
+	for name, codes := range first {
+		t.Run(name, func(t *testing.T) {
+			var got, again bytes.Buffer
+			if err := writeCodesCSV(&got, codes); err != nil {
+				t.Fatal(err)
+			}
+			if err := writeCodesCSV(&again, second[name]); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got.Bytes(), again.Bytes()) {
+				t.Fatal("два построения по одному тексту дали разные таблицы")
+			}
+
+			path := filepath.Join("testdata", name)
+			if *update {
+				if err := os.WriteFile(path, got.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got.Bytes(), want) {
+				t.Errorf("таблица не совпадает с эталоном %s; если изменение намеренное, запустите с -update", path)
+			}
+		})
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"unicode/utf8"
+)
+
+// Универсальные коды целых чисел
+//
+// Коды Элиаса и Фибоначчи определены для n >= 1, коды Голомба и Райса — для n >= 0.
+// Все пишут в bitWriter и читают из bitReader, поэтому годятся для длин серий,
+// расстояний LZ и номеров символов.
+
+// гамма-код Элиаса: floor(log2 n) нулей, затем n в двоичном виде
+func writeEliasGamma(bw *bitWriter, n uint64) {
+	length := bits.Len64(n)
+	bw.writeBits(0, length-1)
+	bw.writeBits(n, length)
+}
+
+func readEliasGamma(br *bitReader) (uint64, error) {
+	zeros := 0
+	for {
+		bit, err := br.readBit()
+		if err != nil {
+			return 0, err
+		}
+		if bit == 1 {
+			break
+		}
+		zeros++
+		if zeros > 63 {
+			return 0, errors.New("слишком длинный гамма-код")
+		}
+	}
+	rest, err := br.readBits(zeros)
+	if err != nil {
+		return 0, err
+	}
+	return 1<<uint(zeros) | rest, nil
+}
+
+// дельта-код Элиаса: длина числа гамма-кодом, затем число без старшей единицы
+func writeEliasDelta(bw *bitWriter, n uint64) {
+	length := bits.Len64(n)
+	writeEliasGamma(bw, uint64(length))
+	bw.writeBits(n, length-1)
+}
+
+func readEliasDelta(br *bitReader) (uint64, error) {
+	length, err := readEliasGamma(br)
+	if err != nil {
+		return 0, err
+	}
+	if length > 64 {
+		return 0, errors.New("слишком длинный дельта-код")
+	}
+	rest, err := br.readBits(int(length - 1))
+	if err != nil {
+		return 0, err
+	}
+	return 1<<(length-1) | rest, nil
+}
+
+// омега-код Элиаса: рекурсивно записанные длины, в конце ноль
+func writeEliasOmega(bw *bitWriter, n uint64) {
+	var groups []uint64
+	for n > 1 {
+		groups = append(groups, n)
+		n = uint64(bits.Len64(n) - 1)
+	}
+	for i := len(groups) - 1; i >= 0; i-- {
+		bw.writeBits(groups[i], bits.Len64(groups[i]))
+	}
+	bw.writeBit(0)
+}
+
+func readEliasOmega(br *bitReader) (uint64, error) {
+	n := uint64(1)
+	for {
+		bit, err := br.readBit()
+		if err != nil {
+			return 0, err
+		}
+		if bit == 0 {
+			return n, nil
+		}
+		if n > 63 {
+			return 0, errors.New("слишком длинный омега-код")
+		}
+		rest, err := br.readBits(int(n))
+		if err != nil {
+			return 0, err
+		}
+		n = 1<<n | rest
+	}
+}
+
+// числа Фибоначчи 1, 2, 3, 5, 8, ... до переполнения uint64
+var fibonacci = func() []uint64 {
+	fib := []uint64{1, 2}
+	for {
+		next := fib[len(fib)-1] + fib[len(fib)-2]
+		if next < fib[len(fib)-1] {
+			return fib
+		}
+		fib = append(fib, next)
+	}
+}()
+
+// код Фибоначчи: представление Цекендорфа от младших членов к старшим и завершающая единица
+func writeFibonacci(bw *bitWriter, n uint64) {
+	top := 0
+	for top+1 < len(fibonacci) && fibonacci[top+1] <= n {
+		top++
+	}
+	digits := make([]uint, top+1)
+	for i := top; i >= 0; i-- {
+		if fibonacci[i] <= n {
+			digits[i] = 1
+			n -= fibonacci[i]
+		}
+	}
+	for _, d := range digits {
+		bw.writeBit(d)
+	}
+	bw.writeBit(1)
+}
+
+func readFibonacci(br *bitReader) (uint64, error) {
+	var n uint64
+	prev := uint(0)
+	for i := 0; ; i++ {
+		bit, err := br.readBit()
+		if err != nil {
+			return 0, err
+		}
+		if bit == 1 && prev == 1 {
+			return n, nil
+		}
+		if i >= len(fibonacci) {
+			return 0, errors.New("слишком длинный код Фибоначчи")
+		}
+		if bit == 1 {
+			n += fibonacci[i]
+		}
+		prev = bit
+	}
+}
+
+// код Голомба с параметром m: частное унарно, остаток усечённым двоичным кодом
+func writeGolomb(bw *bitWriter, n, m uint64) {
+	q, r := n/m, n%m
+	for ; q > 0; q-- {
+		bw.writeBit(1)
+	}
+	bw.writeBit(0)
+	if m == 1 {
+		return
+	}
+	b := bits.Len64(m - 1)
+	cutoff := uint64(1)<<uint(b) - m // первые cutoff остатков кодируются b-1 битами
+	if r < cutoff {
+		bw.writeBits(r, b-1)
+	} else {
+		bw.writeBits(r+cutoff, b)
+	}
+}
+
+func readGolomb(br *bitReader, m uint64) (uint64, error) {
+	var q uint64
+	for {
+		bit, err := br.readBit()
+		if err != nil {
+			return 0, err
+		}
+		if bit == 0 {
+			break
+		}
+		q++
+	}
+	if m == 1 {
+		return q, nil
+	}
+	b := bits.Len64(m - 1)
+	cutoff := uint64(1)<<uint(b) - m
+	r, err := br.readBits(b - 1)
+	if err != nil {
+		return 0, err
+	}
+	if r >= cutoff {
+		low, err := br.readBit()
+		if err != nil {
+			return 0, err
+		}
+		r = (r<<1 | uint64(low)) - cutoff
+	}
+	return q*m + r, nil
+}
+
+// код Райса — код Голомба с m = 2^k
+func writeRice(bw *bitWriter, n uint64, k int) {
+	writeGolomb(bw, n, 1<<uint(k))
+}
+
+func readRice(br *bitReader, k int) (uint64, error) {
+	return readGolomb(br, 1<<uint(k))
+}
+
+// универсальный код: имя и пара функций записи/чтения числа n >= 1
+type integerCode struct {
+	Name  string
+	write func(bw *bitWriter, n uint64)
+	read  func(br *bitReader) (uint64, error)
+}
+
+// набор кодов для сравнения; параметры Голомба и Райса подбираются по среднему значению
+func integerCodes(mean float64) []integerCode {
+	// оптимальный параметр Голомба для геометрического распределения со средним mean
+	m := uint64(math.Max(1, math.Round(mean*math.Ln2)))
+	k := max(bits.Len64(m)-1, 0)
+	return []integerCode{
+		{"Элиас γ", writeEliasGamma, readEliasGamma},
+		{"Элиас δ", writeEliasDelta, readEliasDelta},
+		{"Элиас ω", writeEliasOmega, readEliasOmega},
+		{"Фибоначчи", writeFibonacci, readFibonacci},
+		{fmt.Sprintf("Голомб m=%d", m),
+			func(bw *bitWriter, n uint64) { writeGolomb(bw, n-1, m) },
+			func(br *bitReader) (uint64, error) {
+				n, err := readGolomb(br, m)
+				return n + 1, err
+			}},
+		{fmt.Sprintf("Райс k=%d", k),
+			func(bw *bitWriter, n uint64) { writeRice(bw, n-1, k) },
+			func(br *bitReader) (uint64, error) {
+				n, err := readRice(br, k)
+				return n + 1, err
+			}},
+	}
+}
+
+// Кодирование текста номерами частот
+//
+// Каждый символ заменяется своим номером (с единицы) в алфавите, упорядоченном
+// ByProb, и номер пишется универсальным кодом. Частым символам достаются
+// маленькие номера и короткие коды, а таблица не нужна — только порядок символов.
+func frequencyRanks(text string, alphabet []Symbol) ([]uint64, error) {
+	rank := make(map[string]uint64, len(alphabet))
+	for i, s := range alphabet {
+		rank[s.Char] = uint64(i + 1)
+	}
+	ranks := make([]uint64, 0, len(text))
+	for _, symbol := range textSymbols(text) {
+		n, exists := rank[symbol]
+		if !exists {
+			return nil, fmt.Errorf("символа %q нет в алфавите", symbol)
+		}
+		ranks = append(ranks, n)
+	}
+	return ranks, nil
+}
+
+// результат одного универсального кода на последовательности номеров
+type integerCodeResult struct {
+	Name        string
+	Bits        uint64
+	BitsPerChar float64
+}
+
+// кодирует номера всеми кодами, проверяет декодирование и возвращает размеры
+func compareIntegerCodes(values []uint64) ([]integerCodeResult, error) {
+	var sum float64
+	for _, v := range values {
+		sum += float64(v)
+	}
+	mean := sum / float64(len(values))
+
+	var results []integerCodeResult
+	for _, code := range integerCodes(mean) {
+		var buf bytes.Buffer
+		bw := newBitWriter(&buf)
+		for _, v := range values {
+			code.write(bw, v)
+		}
+		count := bw.count
+		if err := bw.flush(); err != nil {
+			return nil, err
+		}
+
+		br := newBitReader(bytes.NewReader(buf.Bytes()))
+		for i, v := range values {
+			got, err := code.read(br)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", code.Name, err)
+			}
+			if got != v {
+				return nil, fmt.Errorf("%s: на позиции %d прочитано %d вместо %d", code.Name, i, got, v)
+			}
+		}
+		results = append(results, integerCodeResult{code.Name, count, float64(count) / float64(len(values))})
+	}
+	return results, nil
+}
+
+func printIntegerCodeResults(w io.Writer, results []integerCodeResult, text string, huffmanAvg float64) {
+	fmt.Fprintf(w, "  %-14s %12s %12s\n", "Код", "Бит", "Бит/символ")
+	for _, r := range results {
+		fmt.Fprintf(w, "  %-14s %12d %12.4f\n", r.Name, r.Bits, r.BitsPerChar)
+	}
+	fmt.Fprintf(w, "  %-14s %12.0f %12.4f\n", "Хаффман", huffmanAvg*float64(utf8.RuneCountInString(text)), huffmanAvg)
+}
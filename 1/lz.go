@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LZ77: скользящее окно и пары (длина, расстояние)
+//
+// Повторы ищутся по хеш-цепочкам из трёх символов, разбор жадный.
+// Параметры окна и длины совпадения взяты из deflate.
+const (
+	lzWindowSize = 32768
+	lzMinMatch   = 3
+	lzMaxMatch   = 258
+	lzMaxChain   = 64 // сколько кандидатов проверяется для каждой позиции
+	lzHashBits   = 16
+)
+
+// токен LZ77: литерал, если Length == 0, иначе копия Length символов с расстояния Distance
+type lz77Token struct {
+	Literal  rune
+	Length   int
+	Distance int
+}
+
+func lz77Compress(text string) []lz77Token {
+	runes := textRunes(text)
+	head := make([]int, 1<<lzHashBits)
+	for i := range head {
+		head[i] = -1
+	}
+	prev := make([]int, len(runes))
+
+	hash := func(i int) int {
+		h := uint32(runes[i])*2654435761 ^ uint32(runes[i+1])*40503 ^ uint32(runes[i+2])
+		return int(h>>8) & (1<<lzHashBits - 1)
+	}
+	insert := func(i int) {
+		if i+lzMinMatch <= len(runes) {
+			h := hash(i)
+			prev[i] = head[h]
+			head[h] = i
+		}
+	}
+
+	var tokens []lz77Token
+	for i := 0; i < len(runes); {
+		bestLength, bestDistance := 0, 0
+		if i+lzMinMatch <= len(runes) {
+			maxLength := min(lzMaxMatch, len(runes)-i)
+			candidate := head[hash(i)]
+			for chain := 0; candidate >= 0 && i-candidate <= lzWindowSize && chain < lzMaxChain; chain++ {
+				length := 0
+				for length < maxLength && runes[candidate+length] == runes[i+length] {
+					length++
+				}
+				if length > bestLength {
+					bestLength, bestDistance = length, i-candidate
+					if length == maxLength {
+						break
+					}
+				}
+				candidate = prev[candidate]
+			}
+		}
+
+		if bestLength >= lzMinMatch {
+			tokens = append(tokens, lz77Token{Length: bestLength, Distance: bestDistance})
+			for j := 0; j < bestLength; j++ {
+				insert(i + j)
+			}
+			i += bestLength
+		} else {
+			tokens = append(tokens, lz77Token{Literal: runes[i]})
+			insert(i)
+			i++
+		}
+	}
+	return tokens
+}
+
+func lz77Decompress(tokens []lz77Token) (string, error) {
+	var runes []rune
+	for _, t := range tokens {
+		if t.Length == 0 {
+			runes = append(runes, t.Literal)
+			continue
+		}
+		if t.Distance <= 0 || t.Distance > len(runes) {
+			return "", fmt.Errorf("расстояние %d выходит за начало текста", t.Distance)
+		}
+		// копируем посимвольно: источник может перекрываться с тем, что дописывается
+		start := len(runes) - t.Distance
+		for j := 0; j < t.Length; j++ {
+			runes = append(runes, runes[start+j])
+		}
+	}
+	return runesText(runes), nil
+}
+
+// Коды длин и расстояний deflate: база и число дополнительных бит
+var (
+	deflateLengthBase  = []int{3, 4, 5, 6, 7, 8, 9, 10, 11, 13, 15, 17, 19, 23, 27, 31, 35, 43, 51, 59, 67, 83, 99, 115, 131, 163, 195, 227, 258}
+	deflateLengthExtra = []int{0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 2, 2, 2, 2, 3, 3, 3, 3, 4, 4, 4, 4, 5, 5, 5, 5, 0}
+	deflateDistBase    = []int{1, 2, 3, 4, 5, 7, 9, 13, 17, 25, 33, 49, 65, 97, 129, 193, 257, 385, 513, 769, 1025, 1537, 2049, 3073, 4097, 6145, 8193, 12289, 16385, 24577}
+	deflateDistExtra   = []int{0, 0, 0, 0, 1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 6, 6, 7, 7, 8, 8, 9, 9, 10, 10, 11, 11, 12, 12, 13, 13}
+)
+
+// номер интервала, в который попадает значение
+func deflateBucket(base []int, value int) int {
+	return sort.Search(len(base), func(i int) bool { return base[i] > value }) - 1
+}
+
+// Символ кода длины в общем алфавите с литералами. Литерал — строка из одного символа,
+// а код длины — из нескольких, поэтому они не пересекаются.
+func lengthSymbol(bucket int) string {
+	return "<L" + strconv.Itoa(bucket) + ">"
+}
+
+// результат конвейера LZ77 + Хаффман
+type deflateResult struct {
+	Data        []byte
+	Bits        uint64
+	Tokens      int
+	Literals    int
+	Matches     int
+	LitLenCodes map[string]string // литералы и коды длин
+	DistCodes   map[string]string // коды расстояний
+}
+
+// размер вместе с таблицами кодов
+func (d *deflateResult) TotalSize() int {
+	return len(d.Data) + headerSize(d.LitLenCodes) + headerSize(d.DistCodes)
+}
+
+// Конвейер в духе deflate: токены LZ77 кодируются двумя таблицами Хаффмана —
+// литералы вместе с кодами длин и отдельно коды расстояний; дополнительные биты
+// длины и расстояния пишутся как есть.
+func deflateEncode(text string) (*deflateResult, error) {
+	tokens := lz77Compress(text)
+
+	litLenCounts := make(map[string]int)
+	distCounts := make(map[string]int)
+	result := &deflateResult{Tokens: len(tokens)}
+	for _, t := range tokens {
+		if t.Length == 0 {
+			litLenCounts[runeSymbol(t.Literal)]++
+			result.Literals++
+			continue
+		}
+		litLenCounts[lengthSymbol(deflateBucket(deflateLengthBase, t.Length))]++
+		distCounts[strconv.Itoa(deflateBucket(deflateDistBase, t.Distance))]++
+		result.Matches++
+	}
+	result.LitLenCodes = generateHuffmanCodes(alphabetFromCounts(litLenCounts))
+	result.DistCodes = generateHuffmanCodes(alphabetFromCounts(distCounts))
+
+	var buf bytes.Buffer
+	bw := newBitWriter(&buf)
+	for _, t := range tokens {
+		if t.Length == 0 {
+			bw.writeCode(result.LitLenCodes[runeSymbol(t.Literal)])
+			continue
+		}
+		lb := deflateBucket(deflateLengthBase, t.Length)
+		bw.writeCode(result.LitLenCodes[lengthSymbol(lb)])
+		bw.writeBits(uint64(t.Length-deflateLengthBase[lb]), deflateLengthExtra[lb])
+		db := deflateBucket(deflateDistBase, t.Distance)
+		bw.writeCode(result.DistCodes[strconv.Itoa(db)])
+		bw.writeBits(uint64(t.Distance-deflateDistBase[db]), deflateDistExtra[db])
+	}
+	result.Bits = bw.count
+	if err := bw.flush(); err != nil {
+		return nil, err
+	}
+	result.Data = buf.Bytes()
+	return result, nil
+}
+
+func deflateDecode(d *deflateResult) (string, error) {
+	litLenRoot, err := buildDecodeTree(d.LitLenCodes)
+	if err != nil {
+		return "", err
+	}
+	distRoot, err := buildDecodeTree(d.DistCodes)
+	if err != nil {
+		return "", err
+	}
+	lengthBuckets := make(map[string]int, len(deflateLengthBase))
+	for i := range deflateLengthBase {
+		lengthBuckets[lengthSymbol(i)] = i
+	}
+
+	br := newBitReader(bytes.NewReader(d.Data))
+	tokens := make([]lz77Token, 0, d.Tokens)
+	for len(tokens) < d.Tokens {
+		symbol, err := readSymbol(br, litLenRoot)
+		if err != nil {
+			return "", err
+		}
+		lb, isLength := lengthBuckets[symbol]
+		if !isLength {
+			tokens = append(tokens, lz77Token{Literal: symbolRune(symbol)})
+			continue
+		}
+		extra, err := br.readBits(deflateLengthExtra[lb])
+		if err != nil {
+			return "", err
+		}
+		distSymbol, err := readSymbol(br, distRoot)
+		if err != nil {
+			return "", err
+		}
+		db, err := strconv.Atoi(distSymbol)
+		if err != nil {
+			return "", err
+		}
+		distExtra, err := br.readBits(deflateDistExtra[db])
+		if err != nil {
+			return "", err
+		}
+		tokens = append(tokens, lz77Token{
+			Length:   deflateLengthBase[lb] + int(extra),
+			Distance: deflateDistBase[db] + int(distExtra),
+		})
+	}
+	return lz77Decompress(tokens)
+}
+
+// читает один символ, спускаясь по дереву декодирования
+func readSymbol(br *bitReader, root *decodeNode) (string, error) {
+	node := root
+	for !node.leaf {
+		bit, err := br.readBit()
+		if err != nil {
+			return "", err
+		}
+		node = node.child[bit]
+		if node == nil {
+			return "", errUnknownCode
+		}
+	}
+	return node.symbol, nil
+}
+
+// LZW: словарь растёт по мере чтения текста, ширина кода растёт вместе со словарём
+const lzwMaxDictSize = 1 << 16
+
+// результат LZW: начальный словарь (все символы текста) и упакованные коды
+type lzwResult struct {
+	Initial []rune
+	Data    []byte
+	Bits    uint64
+	Codes   int
+}
+
+// размер вместе с начальным словарём, который нужен декодеру
+func (l *lzwResult) TotalSize() int {
+	var buf [binary.MaxVarintLen64]byte
+	return len(l.Data) + binary.PutUvarint(buf[:], uint64(len(l.Initial))) + len(runesText(l.Initial))
+}
+
+// ширина k-го кода: к этому моменту в словаре initial+k записей
+func lzwCodeWidth(initial, k int) int {
+	size := min(initial+k, lzwMaxDictSize)
+	return max(bits.Len(uint(size-1)), 1)
+}
+
+func lzwEncode(text string) (*lzwResult, error) {
+	runes := textRunes(text)
+	seen := make(map[rune]bool)
+	for _, r := range runes {
+		seen[r] = true
+	}
+	result := &lzwResult{}
+	for r := range seen {
+		result.Initial = append(result.Initial, r)
+	}
+	sort.Slice(result.Initial, func(i, j int) bool { return result.Initial[i] < result.Initial[j] })
+
+	dict := make(map[string]int, lzwMaxDictSize)
+	for i, r := range result.Initial {
+		dict[runeSymbol(r)] = i
+	}
+
+	var buf bytes.Buffer
+	bw := newBitWriter(&buf)
+	emit := func(code int) {
+		bw.writeBits(uint64(code), lzwCodeWidth(len(result.Initial), result.Codes))
+		result.Codes++
+	}
+
+	current := ""
+	for _, r := range runes {
+		next := current + runeSymbol(r)
+		if _, exists := dict[next]; exists {
+			current = next
+			continue
+		}
+		emit(dict[current])
+		if len(dict) < lzwMaxDictSize {
+			dict[next] = len(dict)
+		}
+		current = runeSymbol(r)
+	}
+	if current != "" {
+		emit(dict[current])
+	}
+
+	result.Bits = bw.count
+	if err := bw.flush(); err != nil {
+		return nil, err
+	}
+	result.Data = buf.Bytes()
+	return result, nil
+}
+
+func lzwDecode(l *lzwResult) (string, error) {
+	dict := make([]string, 0, lzwMaxDictSize)
+	for _, r := range l.Initial {
+		dict = append(dict, runeSymbol(r))
+	}
+
+	br := newBitReader(bytes.NewReader(l.Data))
+	var decoded strings.Builder
+	previous := ""
+	for k := 0; k < l.Codes; k++ {
+		v, err := br.readBits(lzwCodeWidth(len(l.Initial), k))
+		if err != nil {
+			return "", err
+		}
+		code := int(v)
+
+		var entry string
+		switch {
+		case code < len(dict):
+			entry = dict[code]
+		case code == len(dict) && previous != "":
+			// код ссылается на запись, которую кодер добавил только что: previous + первый символ previous
+			entry = previous + firstSymbol(previous)
+		default:
+			return "", fmt.Errorf("недопустимый код LZW %d", code)
+		}
+		decoded.WriteString(entry)
+
+		if previous != "" && len(dict) < lzwMaxDictSize {
+			dict = append(dict, previous+firstSymbol(entry))
+		}
+		previous = entry
+	}
+	return decoded.String(), nil
+}
+
+func firstSymbol(s string) string {
+	if s == "" {
+		return ""
+	}
+	return textSymbolAt(s, 0)
+}
@@ -2,10 +2,12 @@ package main
 
 import (
 	"encoding/csv"
+	"errors"
 	"fmt"
-	"log"
+	"io"
 	"math"
 	"os"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
@@ -23,106 +25,496 @@ type Symbol struct {
 type ByProb []Symbol
 
 // реализуем интерфйес чтоб можно было сортировать массив наших данных с помощью внутренней функции
-func (a ByProb) Len() int           { return len(a) }
-func (a ByProb) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
-func (a ByProb) Less(i, j int) bool { return a[i].Prob > a[j].Prob }
+func (a ByProb) Len() int      { return len(a) }
+func (a ByProb) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+
+// при равной вероятности порядок задают количество и сам символ, поэтому сортировка
+// даёт один и тот же результат при каждом запуске
+func (a ByProb) Less(i, j int) bool {
+	if a[i].Prob != a[j].Prob {
+		return a[i].Prob > a[j].Prob
+	}
+	if a[i].Count != a[j].Count {
+		return a[i].Count > a[j].Count
+	}
+	return a[i].Char < a[j].Char
+}
 
-func main() {
-	filename := "text.txt"
+// наибольшая длина n-граммы в отчёте об энтропии
+const maxNgramLength = 6
+
+// полный прогон лабораторной: все методы на одном тексте с проверкой декодирования;
+// файлы результатов пишутся в каталог dir, отчёт — в w
+func runDemo(filename, dir string, w io.Writer) error {
 	content, err := os.ReadFile(filename)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	if len(content) == 0 {
+		return fmt.Errorf("%s пуст", filename)
 	}
 	text := string(content)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	out := func(name string) string { return filepath.Join(dir, name) }
 
 	// Одиночные символы
 	alphabet := makeAlphabet(text)
-	writeAlphabetToCSV(alphabet, "alphabet.csv")
+	if err := writeAlphabetToCSV(alphabet, out("alphabet.csv")); err != nil {
+		return err
+	}
 
 	// считаем энтропию(среднее количество информации на символ)
 	entropy := calculateEntropy(alphabet)
 	//Длина равномерного кода(минимальное количество бит для кодирования всех символов)
-	uniformLength := math.Ceil(math.Log2(float64(len(alphabet))))
+	uniformLength := uniformCodeLength(len(alphabet))
 	//вычисляем избыточность
 	redundancy := uniformLength - entropy
 
-	fmt.Printf("Энтропия: %.4f бит/символ\n", entropy)
-	fmt.Printf("Длина равномерного кода: %.0f бит\n", uniformLength)
-	fmt.Printf("Избыточность: %.4f бит\n", redundancy)
+	fmt.Fprintf(w, "Энтропия: %.4f бит/символ\n", entropy)
+	fmt.Fprintf(w, "Длина равномерного кода: %.0f бит\n", uniformLength)
+	fmt.Fprintf(w, "Избыточность: %.4f бит\n", redundancy)
 
 	shannonFanoCodes := generateShannonFanoCodes(alphabet)
-	writeCodesToCSV(shannonFanoCodes, "shannon_fano_codes.csv")
+	if err := checkCodeTable(w, "shannon_fano_codes.csv", shannonFanoCodes, alphabet); err != nil {
+		return err
+	}
+	if err := writeCodesToCSV(shannonFanoCodes, out("shannon_fano_codes.csv")); err != nil {
+		return err
+	}
 
 	avgLength := calculateAverageCodeLength(alphabet, shannonFanoCodes)
 	efficiency := entropy / avgLength
 
-	fmt.Printf("Средняя длина кода: %.4f бит\n", avgLength)
-	fmt.Printf("Эффективность сжатия: %.4f\n", efficiency)
+	fmt.Fprintf(w, "Средняя длина кода: %.4f бит\n", avgLength)
+	fmt.Fprintf(w, "Эффективность сжатия: %.4f\n", efficiency)
 
-	encoded := encodeText(text, shannonFanoCodes)
-	saveToFile(encoded, "encoded.txt")
+	if err := encodeToFile(text, shannonFanoCodes, out("encoded.bin")); err != nil {
+		return err
+	}
+	originalSize := int64(len(content))
+	compressedSize := fileSize(out("encoded.bin"))
+	fmt.Fprintf(w, "Размер исходного файла: %d байт\n", originalSize)
+	fmt.Fprintf(w, "Размер сжатого файла: %d байт\n", compressedSize)
+	fmt.Fprintf(w, "Коэффициент сжатия: %.4f\n", float64(originalSize)/float64(compressedSize))
+
+	// декодирование потоковое: сжатый файл читается и расшифровывается по частям
+	if err := decodeFile(out("encoded.bin"), out("decoded.txt")); err != nil {
+		return err
+	}
+	decoded, err := os.ReadFile(out("decoded.txt"))
+	if err != nil {
+		return err
+	}
 
-	decoded := decodeText(encoded, shannonFanoCodes)
-	saveToFile(decoded, "decoded.txt")
+	// побайтовый режим: сжимаем произвольные двоичные файлы и проверяем восстановление байт в байт
+	binaryFiles, err := filepath.Glob(filepath.Join(filepath.Dir(filename), "*.png"))
+	if err != nil {
+		return err
+	}
+	// файл из одного повторяющегося байта: алфавит из единственного символа
+	scratch, err := os.MkdirTemp("", "lab1-demo")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(scratch)
+	zeros := filepath.Join(scratch, "zeros.bin")
+	if err := os.WriteFile(zeros, make([]byte, 1000), 0644); err != nil {
+		return err
+	}
+	for _, name := range append(binaryFiles, zeros) {
+		sfRatio, err := compressBinaryFile(name, generateShannonFanoCodes)
+		if err != nil {
+			return err
+		}
+		huffmanRatio, err := compressBinaryFile(name, generateCanonicalHuffmanCodes)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Побайтовое сжатие %s: Шеннон-Фано %.4f, Хаффман %.4f, восстановлен без изменений ✓\n",
+			filepath.Base(name), sfRatio, huffmanRatio)
+	}
 
 	// Биграммы(по сути повторяем все те же действия что и выше только для биограм, биограма - 2 идущих подряд символа)
 	bigramAlphabet := makeBigramAlphabet(text)
-	bigramShannonFano := generateShannonFanoCodes(bigramAlphabet)
-	writeCodesToCSV(bigramShannonFano, "bigram_shannon_fano_codes.csv")
+	// символ выхода нужен для пар, которых нет в таблице, и для последнего непарного символа
+	bigramCodingAlphabet := withEscapeSymbol(bigramAlphabet, escapeSymbol, countBigramFallbacks(text, bigramAlphabet))
+	bigramShannonFano := generateShannonFanoCodes(bigramCodingAlphabet)
+	if err := checkCodeTable(w, "bigram_shannon_fano_codes.csv", bigramShannonFano, bigramCodingAlphabet); err != nil {
+		return err
+	}
+	if err := writeCodesToCSV(bigramShannonFano, out("bigram_shannon_fano_codes.csv")); err != nil {
+		return err
+	}
+
+	// канонические коды Хаффмана: в заголовке файла хранятся только длины
+	huffmanCodes := generateCanonicalHuffmanCodes(alphabet)
+	if err := checkCodeTable(w, "huffman_codes.csv", huffmanCodes, alphabet); err != nil {
+		return err
+	}
+	if err := writeCodesToCSV(huffmanCodes, out("huffman_codes.csv")); err != nil {
+		return err
+	}
+
+	// деревья кодов для отрисовки: dot -Tsvg huffman_tree.dot -o huffman_tree.svg;
+	// строятся по тем же таблицам, что записаны в CSV
+	for _, tree := range []struct {
+		name     string
+		codes    map[string]string
+		filename string
+	}{
+		{"huffman", huffmanCodes, "huffman_tree.dot"},
+		{"shannon_fano", shannonFanoCodes, "shannon_fano_tree.dot"},
+	} {
+		root, err := treeFromCodes(tree.codes, alphabet)
+		if err != nil {
+			return err
+		}
+		if err := writeTreeToDOT(root, tree.name, out(tree.filename)); err != nil {
+			return err
+		}
+	}
+
+	// потоковое кодирование прямо из файла: частоты и коды строятся без чтения текста в память
+	if _, err := encodeFile(filename, out("encoded_huffman.bin"), generateCanonicalHuffmanCodes, readRuneSymbol); err != nil {
+		return err
+	}
+	huffmanSize := fileSize(out("encoded_huffman.bin"))
+	fmt.Fprintf(w, "Средняя длина кода Хаффмана: %.4f бит\n", calculateAverageCodeLength(alphabet, huffmanCodes))
+	fmt.Fprintf(w, "Размер сжатого файла (Хаффман): %d байт\n", huffmanSize)
+	fmt.Fprintf(w, "Коэффициент сжатия (Хаффман): %.4f\n", float64(originalSize)/float64(huffmanSize))
+
+	decodedHuffman, err := decodeFromFile(out("encoded_huffman.bin"))
+	if err != nil {
+		return err
+	}
+	if decodedHuffman != text {
+		return errors.New("декодированный текст (Хаффман) не совпадает с исходным")
+	}
 
-	huffmanCodes := generateHuffmanCodes(alphabet)
-	writeCodesToCSV(huffmanCodes, "huffman_codes.csv")
+	// таблицы, выгруженные в CSV, загружаются обратно и декодируют данные, сжатые без таблицы
+	for _, table := range []string{out("shannon_fano_codes.csv"), out("huffman_codes.csv")} {
+		codes, err := loadCodesFromCSV(table)
+		if err != nil {
+			return err
+		}
+		if err := encodeFileWithTable(filename, out("encoded_table.bin"), codes, readRuneSymbol, true); err != nil {
+			return err
+		}
+		if err := decodeFileWithTable(out("encoded_table.bin"), out("decoded_table.txt"), codes); err != nil {
+			return err
+		}
+		decodedTable, err := os.ReadFile(out("decoded_table.txt"))
+		if err != nil {
+			return err
+		}
+		if string(decodedTable) != text {
+			return fmt.Errorf("текст, декодированный по таблице %s, не совпадает с исходным", table)
+		}
+		fmt.Fprintf(w, "Декодирование по таблице %s: %d байт без таблицы ✓\n", table, fileSize(out("encoded_table.bin")))
+	}
+	os.Remove(out("encoded_table.bin"))
+	os.Remove(out("decoded_table.txt"))
 
-	bigramHuffman := generateHuffmanCodes(bigramAlphabet)
-	writeCodesToCSV(bigramHuffman, "bigram_huffman_codes.csv")
+	bigramHuffman := generateCanonicalHuffmanCodes(bigramCodingAlphabet)
+	if err := checkCodeTable(w, "bigram_huffman_codes.csv", bigramHuffman, bigramCodingAlphabet); err != nil {
+		return err
+	}
+	if err := writeCodesToCSV(bigramHuffman, out("bigram_huffman_codes.csv")); err != nil {
+		return err
+	}
+
+	// сравнение кодов Шеннона, Шеннона-Фано, Шеннона-Фано-Элайеса и Хаффмана
+	// те же показатели собираются в отчёт для JSON и Markdown
+	report := analysisReport{Source: filename}
+	for _, unit := range []struct {
+		id, title, filename  string
+		alphabet             []Symbol
+		shannonFano, huffman map[string]string
+	}{
+		{"char", "Символы", "code_comparison.csv", alphabet, shannonFanoCodes, huffmanCodes},
+		{"bigram", "Биграммы", "bigram_code_comparison.csv", bigramCodingAlphabet, bigramShannonFano, bigramHuffman},
+	} {
+		methods := []codeMethod{
+			{"shannon", "Шеннон", generateShannonCodes(unit.alphabet)},
+			{"shannon-fano", "Шеннон-Фано", unit.shannonFano},
+			{"shannon-fano-elias", "Шеннон-Фано-Элайес", generateShannonFanoEliasCodes(unit.alphabet)},
+			{"huffman", "Хаффман", unit.huffman},
+		}
+		for _, m := range methods {
+			if err := checkCodeTable(w, unit.title+": "+m.Name, m.Codes, unit.alphabet); err != nil {
+				return err
+			}
+		}
+		if err := writeCodeComparisonCSV(unit.alphabet, methods, out(unit.filename)); err != nil {
+			return err
+		}
+		printCodeComparison(w, unit.title, unit.alphabet, methods)
+		report.Alphabets = append(report.Alphabets, newAlphabetReport(unit.id, unit.title, unit.alphabet, methods))
+	}
+	if err := writeReportFiles(report, out("report.json"), out("report.md")); err != nil {
+		return err
+	}
+
+	// кодируем текст биграммами и сравниваем с посимвольными кодами и энтропией
+	bigramSFEncoded, err := encodeBigramText(text, bigramShannonFano, shannonFanoCodes)
+	if err != nil {
+		return err
+	}
+	bigramHuffmanEncoded, err := encodeBigramText(text, bigramHuffman, huffmanCodes)
+	if err != nil {
+		return err
+	}
+	for _, check := range []struct {
+		encoded                string
+		bigramCodes, charCodes map[string]string
+	}{
+		{bigramSFEncoded, bigramShannonFano, shannonFanoCodes},
+		{bigramHuffmanEncoded, bigramHuffman, huffmanCodes},
+	} {
+		decodedBigrams, err := decodeBigramText(check.encoded, check.bigramCodes, check.charCodes)
+		if err != nil {
+			return err
+		}
+		if decodedBigrams != text {
+			return errors.New("декодированный биграммами текст не совпадает с исходным")
+		}
+	}
+
+	arithmeticData, arithmeticBits, err := arithmeticEncode(text, alphabet)
+	if err != nil {
+		return err
+	}
+	textLength := utf8.RuneCountInString(text)
+	decodedArithmetic, err := arithmeticDecode(arithmeticData, alphabet, textLength)
+	if err != nil {
+		return err
+	}
+	if decodedArithmetic != text {
+		return errors.New("арифметически декодированный текст не совпадает с исходным")
+	}
+
+	// коды Тунсталла с кодовыми словами разной ширины
+	tunstallBits := []int{10, 12, 16}
+	tunstallRates := make([]float64, len(tunstallBits))
+	for i, bits := range tunstallBits {
+		if tunstallRates[i], err = tunstallBitsPerChar(text, alphabet, bits); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintln(w, "Бит на символ исходного текста:")
+	fmt.Fprintf(w, "  Энтропия H1: %.4f\n", entropy)
+	fmt.Fprintf(w, "  Энтропия H2/2: %.4f\n", calculateEntropy(bigramAlphabet)/2)
+	fmt.Fprintf(w, "  Шеннон-Фано (символы): %.4f\n", avgLength)
+	fmt.Fprintf(w, "  Хаффман (символы): %.4f\n", calculateAverageCodeLength(alphabet, huffmanCodes))
+	fmt.Fprintf(w, "  Арифметическое (символы): %.4f\n", float64(arithmeticBits)/float64(textLength))
+	for i, bits := range tunstallBits {
+		fmt.Fprintf(w, "  Тунсталл (слова по %d бит): %.4f\n", bits, tunstallRates[i])
+	}
+	fmt.Fprintf(w, "  Шеннон-Фано (биграммы): %.4f\n", bitsPerChar(bigramSFEncoded, text))
+	fmt.Fprintf(w, "  Хаффман (биграммы): %.4f\n", bitsPerChar(bigramHuffmanEncoded, text))
+
+	// rANS и tANS против Хаффмана: размер и скорость
+	ansResults, err := compareANSWithHuffman(text, alphabet, huffmanCodes)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "Сравнение ANS и Хаффмана:")
+	printCoderBenchmarks(w, ansResults)
+
+	// табличный декодер; скорость decodeText, дерева и таблицы — go test -bench Decode
+	if err := checkFastDecoder(text, huffmanCodes, fastLookupBits); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "✓ Табличный декодер восстанавливает текст")
+
+	// адаптивный Хаффман за один проход против статического с таблицей в заголовке
+	adaptiveData, adaptiveBits, err := adaptiveHuffmanEncode(text)
+	if err != nil {
+		return err
+	}
+	decodedAdaptive, err := adaptiveHuffmanDecode(adaptiveData, textLength)
+	if err != nil {
+		return err
+	}
+	if decodedAdaptive != text {
+		return errors.New("адаптивно декодированный текст не совпадает с исходным")
+	}
+	fmt.Fprintln(w, "Адаптивный Хаффман (FGK):")
+	fmt.Fprintf(w, "  Размер: %d байт, %.4f бит/символ\n", len(adaptiveData), float64(adaptiveBits)/float64(textLength))
+	fmt.Fprintf(w, "  Статический Хаффман с таблицей: %d байт\n", huffmanSize)
+
+	// словарные методы: LZ77 + Хаффман и LZW против посимвольного Хаффмана
+	deflated, err := deflateEncode(text)
+	if err != nil {
+		return err
+	}
+	inflated, err := deflateDecode(deflated)
+	if err != nil {
+		return err
+	}
+	if inflated != text {
+		return errors.New("текст после LZ77 + Хаффман не совпадает с исходным")
+	}
+	lzw, err := lzwEncode(text)
+	if err != nil {
+		return err
+	}
+	decodedLZW, err := lzwDecode(lzw)
+	if err != nil {
+		return err
+	}
+	if decodedLZW != text {
+		return errors.New("текст после LZW не совпадает с исходным")
+	}
+	fmt.Fprintln(w, "Словарные методы (размер вместе с таблицами):")
+	fmt.Fprintf(w, "  Хаффман по символам: %d байт, %.4f бит/символ\n", huffmanSize, float64(huffmanSize*8)/float64(textLength))
+	fmt.Fprintf(w, "  LZ77 + Хаффман: %d байт, %.4f бит/символ (литералов %d, совпадений %d)\n",
+		deflated.TotalSize(), float64(deflated.TotalSize()*8)/float64(textLength), deflated.Literals, deflated.Matches)
+	fmt.Fprintf(w, "  LZW: %d байт, %.4f бит/символ (кодов %d)\n",
+		lzw.TotalSize(), float64(lzw.TotalSize()*8)/float64(textLength), lzw.Codes)
+
+	// BWT + MTF + RLE перед Хаффманом
+	transformed, err := bwtEncode(text)
+	if err != nil {
+		return err
+	}
+	restored, err := bwtDecode(transformed)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "BWT + MTF + RLE + Хаффман:")
+	fmt.Fprintf(w, "  Энтропия исходного текста: %.4f бит/символ\n", entropy)
+	fmt.Fprintf(w, "  Энтропия после MTF: %.4f бит/символ (нулей %.1f%%)\n", transformed.MTFEntropy, zeroShare(transformed.MTF))
+	fmt.Fprintf(w, "  Энтропия после RLE: %.4f бит/символ, символов %d\n", transformed.RLEEntropy, transformed.Symbols)
+	fmt.Fprintf(w, "  Размер: %d байт, %.4f бит/символ текста\n", transformed.TotalLength, float64(transformed.TotalLength*8)/float64(textLength))
+	if restored == string(decoded) {
+		fmt.Fprintln(w, "  ✓ Обратное преобразование совпадает с decoded.txt")
+	} else {
+		return errors.New("обратное преобразование BWT не совпадает с decoded.txt")
+	}
+
+	// текст как последовательность номеров частот, закодированных универсальными кодами
+	ranks, err := frequencyRanks(text, alphabet)
+	if err != nil {
+		return err
+	}
+	integerResults, err := compareIntegerCodes(ranks)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "Универсальные коды номеров символов (по убыванию частоты):")
+	printIntegerCodeResults(w, integerResults, text, calculateAverageCodeLength(alphabet, huffmanCodes))
+
+	// коды Хаффмана с ограниченной длиной для алфавита биграмм
+	fmt.Fprintln(w, "Хаффман с ограничением длины кода (биграммы):")
+	if err := printLengthLimitedComparison(w, bigramCodingAlphabet, []int{12, 13, 14, 16}); err != nil {
+		return err
+	}
+
+	// код строится по первой половине текста и применяется ко второй
+	symbols := textSymbols(text)
+	half := len(symbols) / 2
+	train, test := strings.Join(symbols[:half], ""), strings.Join(symbols[half:], "")
+	cross, err := crossCorpus(train, test)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "Код по первой половине текста на второй половине:")
+	printCrossCorpusReport(w, cross)
+
+	// символы второй половины, которых нет в первой, проходят через выход; без выхода — ошибка
+	escapedCodes := withEscape(generateCanonicalHuffmanCodes)(makeAlphabet(train))
+	if _, err := encodeText(test, escapedCodes, true); err != nil {
+		fmt.Fprintln(w, "Строгий режим:", err)
+	}
+	escapedTest, err := encodeText(test, escapedCodes, false)
+	if err != nil {
+		return err
+	}
+	decodedTest, err := decodeText(escapedTest, escapedCodes)
+	if err != nil {
+		return err
+	}
+	if err := encodeToFile(test, escapedCodes, out("encoded_escape.bin")); err != nil {
+		return err
+	}
+	decodedContainer, err := decodeFromFile(out("encoded_escape.bin"))
+	os.Remove(out("encoded_escape.bin"))
+	if err != nil {
+		return err
+	}
+	if decodedTest != test || decodedContainer != test {
+		return errors.New("текст с символами выхода декодирован неверно")
+	}
+	fmt.Fprintln(w, "✓ Символы без кода восстановлены через выход")
+	// без последнего бита последний код становится неполным, если он длиннее одного бита
+	if symbols := textSymbols(test); len(symbols) > 0 {
+		if bits, _ := symbolBits(symbols[len(symbols)-1], escapedCodes); bits > 1 {
+			if _, err := decodeText(escapedTest[:len(escapedTest)-1], escapedCodes); err != nil {
+				fmt.Fprintln(w, "Обрезанный поток:", err)
+			} else {
+				return errors.New("обрезанный поток декодирован без ошибки")
+			}
+		}
+	}
+
+	// одиночная битовая ошибка в потоках Шеннона-Фано и Хаффмана и самосинхронизация декодера
+	if err := runBitErrorExperiment(w, text, bitErrorTrials, bitErrorSeed, out("bit_errors.csv"), out("bit_errors.svg")); err != nil {
+		return err
+	}
+
+	// энтропия n-грамм для оценки энтропии источника
+	ngramEntropies := calculateNgramEntropies(text, maxNgramLength)
+	printNgramEntropies(w, ngramEntropies)
+	return writeNgramEntropiesToCSV(ngramEntropies, out("ngram_entropy.csv"))
 }
 
 // Алфавит одиночных символов
+func makeAlphabet(text string) []Symbol {
+	return makeNgramAlphabet(text, 1)
+}
+
+// Алфавит биграмм
+func makeBigramAlphabet(text string) []Symbol {
+	return makeNgramAlphabet(text, 2)
+}
+
+// Алфавит n-грамм (n идущих подряд символов, окна перекрываются)
 //
-// # Подсчитывает количество каждого символа
+// # Подсчитывает количество каждой n-граммы
 //
 // # Вычисляет вероятности появления
 //
 // Сортирует по убыванию вероятности
-func makeAlphabet(text string) []Symbol {
-	counts := make(map[string]int)
-	total := 0
-
-	for _, r := range text {
-		char := string(r)
-		counts[char]++
-		total++
+func makeNgramAlphabet(text string, n int) []Symbol {
+	// offsets[i] — начало i-го символа; недопустимые байты остаются отдельными символами
+	var offsets []int
+	for i := 0; i < len(text); i += len(textSymbolAt(text, i)) {
+		offsets = append(offsets, i)
 	}
+	offsets = append(offsets, len(text))
 
-	alphabet := make([]Symbol, 0, len(counts))
-	for char, count := range counts {
-		alphabet = append(alphabet, Symbol{
-			Char:  char,
-			Prob:  float64(count) / float64(total),
-			Count: count,
-		})
+	counts := make(map[string]int)
+	for i := 0; i+n < len(offsets); i++ {
+		counts[text[offsets[i]:offsets[i+n]]]++
 	}
-
-	sort.Sort(ByProb(alphabet))
-	return alphabet
+	return alphabetFromCounts(counts)
 }
 
-// Алфавит биграмм
-func makeBigramAlphabet(text string) []Symbol {
-	runes := []rune(text)
-	counts := make(map[string]int)
+// строит алфавит по готовым счётчикам, отсортированный по убыванию вероятности
+func alphabetFromCounts(counts map[string]int) []Symbol {
 	total := 0
-
-	for i := 0; i < len(runes)-1; i++ {
-		bigram := string(runes[i : i+2])
-		counts[bigram]++
-		total++
+	for _, count := range counts {
+		total += count
 	}
 
 	alphabet := make([]Symbol, 0, len(counts))
-	for bigram, count := range counts {
+	for char, count := range counts {
 		alphabet = append(alphabet, Symbol{
-			Char:  bigram,
+			Char:  char,
 			Prob:  float64(count) / float64(total),
 			Count: count,
 		})
@@ -132,16 +524,14 @@ func makeBigramAlphabet(text string) []Symbol {
 	return alphabet
 }
 
-func writeAlphabetToCSV(alphabet []Symbol, filename string) {
+func writeAlphabetToCSV(alphabet []Symbol, filename string) error {
 	file, err := os.Create(filename)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	defer file.Close()
 
 	writer := csv.NewWriter(file)
-	defer writer.Flush()
-
 	writer.Write([]string{"Символ", "Частота", "Вероятность"})
 	for _, s := range alphabet {
 		displayChar := escapeSpecialChars(s.Char)
@@ -151,16 +541,28 @@ func writeAlphabetToCSV(alphabet []Symbol, filename string) {
 			strconv.FormatFloat(s.Prob, 'f', 6, 64),
 		})
 	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	return file.Close()
 }
 
-func writeCodesToCSV(codes map[string]string, filename string) {
+func writeCodesToCSV(codes map[string]string, filename string) error {
 	file, err := os.Create(filename)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	defer file.Close()
 
-	writer := csv.NewWriter(file)
+	if err := writeCodesCSV(file, codes); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+func writeCodesCSV(w io.Writer, codes map[string]string) error {
+	writer := csv.NewWriter(w)
 	writer.Write([]string{"Символ", "Код"})
 
 	// Сортируем для вывода
@@ -176,19 +578,43 @@ func writeCodesToCSV(codes map[string]string, filename string) {
 		writer.Write([]string{displayChar, code})
 	}
 	writer.Flush()
+	return writer.Error()
+}
 
-	if err := writer.Error(); err != nil {
-		log.Fatal(err)
+// экранирует управляющие символы для CSV; обратная косая черта экранируется тоже,
+// а прочие управляющие символы и байты вне UTF-8 (побайтовый алфавит) пишутся
+// как \xHH, поэтому запись обратима (см. unescapeSpecialChars)
+func escapeSpecialChars(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == '\\':
+			b.WriteString("\\\\")
+		case r == '\n':
+			b.WriteString("\\n")
+		case r == '\t':
+			b.WriteString("\\t")
+		case r == '\r':
+			b.WriteString("\\r")
+		case r == '"':
+			b.WriteString("\\\"")
+		case r == utf8.RuneError && size == 1, r < 0x20, r == 0x7F:
+			fmt.Fprintf(&b, "\\x%02X", s[i])
+		default:
+			b.WriteRune(r)
+		}
+		i += size
 	}
+	return b.String()
 }
 
-func escapeSpecialChars(s string) string {
-	return strings.NewReplacer(
-		"\n", "\\n",
-		"\t", "\\t",
-		"\r", "\\r",
-		"\"", "\\\"",
-	).Replace(s)
+// длина равномерного кода для n символов; для пустого и одного символа — 0
+func uniformCodeLength(n int) float64 {
+	if n <= 1 {
+		return 0
+	}
+	return math.Ceil(math.Log2(float64(n)))
 }
 
 // вычисляет энтропию
@@ -206,24 +632,31 @@ func calculateEntropy(alphabet []Symbol) float64 {
 //
 // рекурсивно делит символы на две группы с примерно равными вероятностями, левой ветке присваиваем 0, правой ветке 1
 func generateShannonFanoCodes(alphabet []Symbol) map[string]string {
-	codes := make(map[string]string)
+	return codesFromTree(buildShannonFanoTree(alphabet))
+}
+
+// дерево разбиений Шеннона-Фано: у каждого узла — его группа символов
+func buildShannonFanoTree(alphabet []Symbol) *codeTree {
 	if len(alphabet) == 0 {
-		return codes
+		return nil
 	}
 
-	var assignCodes func(symbols []Symbol, code string)
-	assignCodes = func(symbols []Symbol, code string) {
+	var split func(symbols []Symbol) *codeTree
+	split = func(symbols []Symbol) *codeTree {
+		node := &codeTree{Symbols: symbols}
+		for _, s := range symbols {
+			node.Prob += s.Prob
+		}
 		if len(symbols) == 1 {
-			codes[symbols[0].Char] = code
-			return
+			return node
 		}
 		splitIndex := findSplitIndex(symbols)
-		assignCodes(symbols[:splitIndex], code+"0")
-		assignCodes(symbols[splitIndex:], code+"1")
+		node.Left = split(symbols[:splitIndex])
+		node.Right = split(symbols[splitIndex:])
+		return node
 	}
 
-	assignCodes(alphabet, "")
-	return codes
+	return split(alphabet)
 }
 
 func findSplitIndex(symbols []Symbol) int {
@@ -243,47 +676,49 @@ func findSplitIndex(symbols []Symbol) int {
 }
 
 func generateHuffmanCodes(alphabet []Symbol) map[string]string {
-	type Node struct {
-		Symbols []Symbol
-		Prob    float64
-		Left    *Node
-		Right   *Node
-	}
+	return codesFromTree(buildHuffmanTree(alphabet))
+}
 
-	nodes := make([]*Node, len(alphabet))
-	for i, s := range alphabet {
-		nodes[i] = &Node{Symbols: []Symbol{s}, Prob: s.Prob}
+// дерево слияний Хаффмана; корень — nil для пустого алфавита
+func buildHuffmanTree(alphabet []Symbol) *codeTree {
+	// листья по возрастанию вероятности (порядок ByProb в обратную сторону)
+	sorted := append([]Symbol{}, alphabet...)
+	sort.Sort(sort.Reverse(ByProb(sorted)))
+	leaves := make([]*codeTree, len(sorted))
+	for i, s := range sorted {
+		leaves[i] = &codeTree{Symbols: []Symbol{s}, Prob: s.Prob}
 	}
 
-	for len(nodes) > 1 {
-		sort.Slice(nodes, func(i, j int) bool { return nodes[i].Prob < nodes[j].Prob })
-		left, right := nodes[0], nodes[1]
-		newNode := &Node{
+	// Две очереди: листья и новые узлы. Новые узлы появляются в порядке неубывания
+	// вероятности, поэтому две самые лёгкие вершины всегда в начале очередей.
+	// При равных вероятностях лист берётся раньше узла — так слияния не зависят
+	// от порядка сортировки и коды получаются одинаковыми при каждом запуске.
+	var merged []*codeTree
+	pick := func() *codeTree {
+		var node *codeTree
+		if len(merged) == 0 || len(leaves) > 0 && leaves[0].Prob <= merged[0].Prob {
+			node, leaves = leaves[0], leaves[1:]
+		} else {
+			node, merged = merged[0], merged[1:]
+		}
+		return node
+	}
+	for len(leaves)+len(merged) > 1 {
+		left := pick()
+		right := pick()
+		merged = append(merged, &codeTree{
 			Symbols: append(append([]Symbol{}, left.Symbols...), right.Symbols...),
 			Prob:    left.Prob + right.Prob,
 			Left:    left,
 			Right:   right,
-		}
-		nodes = append(nodes[2:], newNode)
-	}
-
-	codes := make(map[string]string)
-	var traverse func(node *Node, code string)
-	traverse = func(node *Node, code string) {
-		if node.Left == nil && node.Right == nil {
-			if len(node.Symbols) == 1 {
-				codes[node.Symbols[0].Char] = code
-			}
-			return
-		}
-		traverse(node.Left, code+"0")
-		traverse(node.Right, code+"1")
+		})
 	}
+	nodes := append(leaves, merged...)
 
-	if len(nodes) > 0 {
-		traverse(nodes[0], "")
+	if len(nodes) == 0 {
+		return nil
 	}
-	return codes
+	return nodes[0]
 }
 
 func calculateAverageCodeLength(alphabet []Symbol, codes map[string]string) float64 {
@@ -296,39 +731,87 @@ func calculateAverageCodeLength(alphabet []Symbol, codes map[string]string) floa
 	return avg
 }
 
-func encodeText(text string, codes map[string]string) string {
+// кодирует текст в строку из '0' и '1'
+//
+// Символ без кода записывается кодом выхода и литералом: длиной и байтами символа
+// по 8 бит (см. escape.go). В строгом режиме (strict) или без символа выхода в таблице
+// такой символ — ошибка.
+func encodeText(text string, codes map[string]string, strict bool) (string, error) {
 	var encoded strings.Builder
-	for _, r := range text {
-		char := string(r)
+	for i := 0; i < len(text); {
+		char := textSymbolAt(text, i)
+		i += len(char)
 		if code, exists := codes[char]; exists {
 			encoded.WriteString(code)
+			continue
+		}
+		escape, exists := codes[literalEscapeSymbol]
+		if strict || !exists {
+			return "", fmt.Errorf("символа %q нет в таблице кодов", char)
+		}
+		if len(char) > maxLiteralLength {
+			return "", fmt.Errorf("символ %q длиннее %d байт", char, maxLiteralLength)
+		}
+		encoded.WriteString(escape)
+		fmt.Fprintf(&encoded, "%08b", len(char))
+		for i := 0; i < len(char); i++ {
+			fmt.Fprintf(&encoded, "%08b", char[i])
 		}
 	}
-	return encoded.String()
+	return encoded.String(), nil
 }
 
-func decodeText(encoded string, codes map[string]string) string {
+// декодирует строку из '0' и '1'; лишние или недопустимые биты — ошибка, а не потеря данных
+func decodeText(encoded string, codes map[string]string) (string, error) {
 	reverseCodes := make(map[string]string)
+	maxLength := 0
 	for char, code := range codes {
 		reverseCodes[code] = char
+		maxLength = max(maxLength, len(code))
 	}
 
 	var decoded strings.Builder
 	var current strings.Builder
 
-	for _, bit := range encoded {
-		current.WriteRune(bit)
-		if char, exists := reverseCodes[current.String()]; exists {
-			decoded.WriteString(char)
-			current.Reset()
+	for i := 0; i < len(encoded); i++ {
+		if encoded[i] != '0' && encoded[i] != '1' {
+			return "", fmt.Errorf("позиция %d: символ %q вместо бита", i, encoded[i])
+		}
+		current.WriteByte(encoded[i])
+		char, exists := reverseCodes[current.String()]
+		if !exists {
+			if current.Len() >= maxLength {
+				return "", fmt.Errorf("позиция %d: %v", i, errUnknownCode)
+			}
+			continue
 		}
+		if char == escapeSymbol {
+			return "", fmt.Errorf("позиция %d: %v", i, errBigramEscape)
+		}
+		if char == literalEscapeSymbol {
+			literal, err := readLiteral(func() (byte, error) {
+				if i+8 >= len(encoded) {
+					return 0, errTruncated
+				}
+				b, err := strconv.ParseUint(encoded[i+1:i+9], 2, 8)
+				i += 8
+				return byte(b), err
+			})
+			if err != nil {
+				return "", fmt.Errorf("позиция %d: %v", i, err)
+			}
+			char = literal
+		}
+		decoded.WriteString(char)
+		current.Reset()
+	}
+	if current.Len() > 0 {
+		return "", fmt.Errorf("после последнего символа осталось %d бит: %v", current.Len(), errTruncated)
 	}
 
-	return decoded.String()
+	return decoded.String(), nil
 }
 
-func saveToFile(content, filename string) {
-	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
-		log.Fatal(err)
-	}
+func saveToFile(content, filename string) error {
+	return os.WriteFile(filename, []byte(content), 0644)
 }
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// энтропийные характеристики блоков длины N
+type NgramEntropy struct {
+	N           int
+	Block       float64 // блочная энтропия H_n
+	PerChar     float64 // H_n / n
+	Conditional float64 // условная энтропия H(X_n | X_1..X_{n-1}) = H_n - H_{n-1}
+}
+
+// считает энтропии n-грамм для n = 1..maxN
+//
+// Условная энтропия убывает с ростом n и вместе с H_n/n оценивает сверху
+// энтропию источника на символ.
+func calculateNgramEntropies(text string, maxN int) []NgramEntropy {
+	result := make([]NgramEntropy, 0, maxN)
+	prev := 0.0
+	for n := 1; n <= maxN; n++ {
+		block := calculateEntropy(makeNgramAlphabet(text, n))
+		result = append(result, NgramEntropy{
+			N:           n,
+			Block:       block,
+			PerChar:     block / float64(n),
+			Conditional: block - prev,
+		})
+		prev = block
+	}
+	return result
+}
+
+func printNgramEntropies(w io.Writer, entropies []NgramEntropy) {
+	fmt.Fprintln(w, "Энтропия n-грамм (бит):")
+	fmt.Fprintf(w, "  %2s %10s %10s %16s\n", "n", "H_n", "H_n/n", "H(X_n|X_1..n-1)")
+	for _, e := range entropies {
+		fmt.Fprintf(w, "  %2d %10.4f %10.4f %16.4f\n", e.N, e.Block, e.PerChar, e.Conditional)
+	}
+}
+
+func writeNgramEntropiesToCSV(entropies []NgramEntropy, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"n", "H_n", "H_n/n", "H(X_n|X_1..X_n-1)"})
+	for _, e := range entropies {
+		writer.Write([]string{
+			strconv.Itoa(e.N),
+			strconv.FormatFloat(e.Block, 'f', 6, 64),
+			strconv.FormatFloat(e.PerChar, 'f', 6, 64),
+			strconv.FormatFloat(e.Conditional, 'f', 6, 64),
+		})
+	}
+	writer.Flush()
+
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Коды Хаффмана с ограниченной длиной (алгоритм package-merge)
+//
+// Каждый символ — «монета» с весом, равным его частоте. На каждом из maxLength
+// уровней монеты попарно склеиваются в пакеты, и пакеты сливаются с исходными
+// монетами в порядке возрастания веса. Длина кода символа равна числу его
+// вхождений в 2n-2 самых лёгких элементов последнего уровня. Получается
+// оптимальный префиксный код среди кодов с длинами не больше maxLength.
+type coinNode struct {
+	weight      int
+	symbol      int // номер символа для монеты, -1 для пакета
+	left, right *coinNode
+}
+
+func generateLengthLimitedHuffmanCodes(alphabet []Symbol, maxLength int) (map[string]string, error) {
+	n := len(alphabet)
+	if n == 0 {
+		return map[string]string{}, nil
+	}
+	if n == 1 {
+		return map[string]string{alphabet[0].Char: "0"}, nil
+	}
+	if maxLength < 1 || maxLength < 64 && 1<<uint(maxLength) < n {
+		return nil, fmt.Errorf("%d символов не помещаются в коды длиной до %d бит", n, maxLength)
+	}
+
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		sa, sb := alphabet[order[a]], alphabet[order[b]]
+		if sa.Count != sb.Count {
+			return sa.Count < sb.Count
+		}
+		return sa.Char < sb.Char
+	})
+	coins := make([]*coinNode, n)
+	for i, idx := range order {
+		coins[i] = &coinNode{weight: alphabet[idx].Count, symbol: idx}
+	}
+
+	items := coins
+	for level := 1; level < maxLength; level++ {
+		packages := make([]*coinNode, 0, len(items)/2)
+		for i := 0; i+1 < len(items); i += 2 {
+			packages = append(packages, &coinNode{
+				weight: items[i].weight + items[i+1].weight,
+				symbol: -1,
+				left:   items[i],
+				right:  items[i+1],
+			})
+		}
+		items = mergeCoins(coins, packages)
+	}
+
+	lengths := make(map[string]int, n)
+	var count func(node *coinNode)
+	count = func(node *coinNode) {
+		if node.symbol >= 0 {
+			lengths[alphabet[node.symbol].Char]++
+			return
+		}
+		count(node.left)
+		count(node.right)
+	}
+	for _, item := range items[:2*n-2] {
+		count(item)
+	}
+	return canonicalCodes(lengths), nil
+}
+
+// сливает два отсортированных по весу списка; при равном весе монета идёт раньше пакета
+func mergeCoins(coins, packages []*coinNode) []*coinNode {
+	result := make([]*coinNode, 0, len(coins)+len(packages))
+	i, j := 0, 0
+	for i < len(coins) && j < len(packages) {
+		if coins[i].weight <= packages[j].weight {
+			result = append(result, coins[i])
+			i++
+		} else {
+			result = append(result, packages[j])
+			j++
+		}
+	}
+	result = append(result, coins[i:]...)
+	return append(result, packages[j:]...)
+}
+
+func maxCodeLength(codes map[string]string) int {
+	longest := 0
+	for _, code := range codes {
+		longest = max(longest, len(code))
+	}
+	return longest
+}
+
+// сравнивает коды с ограничением длины с обычным Хаффманом
+func printLengthLimitedComparison(w io.Writer, alphabet []Symbol, limits []int) error {
+	unlimited := generateHuffmanCodes(alphabet)
+	unlimitedAvg := calculateAverageCodeLength(alphabet, unlimited)
+	fmt.Fprintf(w, "  %-14s %12s %14s %10s\n", "Ограничение", "Макс. длина", "Средняя длина", "Потеря")
+	fmt.Fprintf(w, "  %-14s %12d %14.4f %10s\n", "нет", maxCodeLength(unlimited), unlimitedAvg, "-")
+	for _, limit := range limits {
+		codes, err := generateLengthLimitedHuffmanCodes(alphabet, limit)
+		if err != nil {
+			return err
+		}
+		avg := calculateAverageCodeLength(alphabet, codes)
+		fmt.Fprintf(w, "  %-14d %12d %14.4f %10.4f\n", limit, maxCodeLength(codes), avg, avg-unlimitedAvg)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"math/bits"
+	"strconv"
+	"testing"
+)
+
+// алфавит с частотами Фибоначчи: у Хаффмана для него самое глубокое дерево
+func fibonacciAlphabet(n int) []Symbol {
+	counts := make(map[string]int, n)
+	a, b := 1, 1
+	for i := 0; i < n; i++ {
+		counts[strconv.Itoa(i)] = a
+		a, b = b, a+b
+	}
+	return alphabetFromCounts(counts)
+}
+
+// суммарная длина закодированного текста в битах
+func weightedLength(alphabet []Symbol, codes map[string]string) int {
+	total := 0
+	for _, s := range alphabet {
+		total += s.Count * len(codes[s.Char])
+	}
+	return total
+}
+
+func TestLengthLimitedHuffmanCodes(t *testing.T) {
+	alphabets := map[string][]Symbol{
+		"Фибоначчи, 20 символов": fibonacciAlphabet(20),
+		"Фибоначчи, 40 символов": fibonacciAlphabet(40),
+	}
+	for name, input := range roundTripInputs() {
+		if input != "" {
+			alphabets[name] = makeAlphabet(input)
+		}
+	}
+
+	for _, name := range sortedKeys(alphabets) {
+		alphabet := alphabets[name]
+		huffman := generateHuffmanCodes(alphabet)
+		huffmanLength := weightedLength(alphabet, huffman)
+		shortest := max(bits.Len(uint(len(alphabet)-1)), 1)
+
+		if _, err := generateLengthLimitedHuffmanCodes(alphabet, shortest-1); err == nil && len(alphabet) > 1 {
+			t.Errorf("%s: %d символов в кодах до %d бит, ожидалась ошибка", name, len(alphabet), shortest-1)
+		}
+		for limit := shortest; limit <= maxCodeLength(huffman)+2; limit++ {
+			codes, err := generateLengthLimitedHuffmanCodes(alphabet, limit)
+			if err != nil {
+				t.Errorf("%s, до %d бит: %v", name, limit, err)
+				continue
+			}
+			if len(codes) != len(alphabet) {
+				t.Errorf("%s, до %d бит: %d кодов на %d символов", name, limit, len(codes), len(alphabet))
+			}
+			if longest := maxCodeLength(codes); longest > limit {
+				t.Errorf("%s, до %d бит: самый длинный код %d бит", name, limit, longest)
+			}
+			// неравенство Крафта в целых числах: сумма 2^(limit-длина) не больше 2^limit
+			kraft := uint64(0)
+			for _, code := range codes {
+				kraft += 1 << uint(limit-len(code))
+			}
+			if kraft > 1<<uint(limit) {
+				t.Errorf("%s, до %d бит: сумма Крафта %d/%d больше 1", name, limit, kraft, uint64(1)<<uint(limit))
+			}
+
+			// при равных частотах длины отдельных символов могут отличаться,
+			// поэтому с Хаффманом сравнивается суммарная длина текста
+			length := weightedLength(alphabet, codes)
+			if length < huffmanLength {
+				t.Errorf("%s, до %d бит: %d бит короче Хаффмана (%d)", name, limit, length, huffmanLength)
+			}
+			if limit >= maxCodeLength(huffman) && length != huffmanLength {
+				t.Errorf("%s, до %d бит: %d бит, а у Хаффмана без ограничения %d", name, limit, length, huffmanLength)
+			}
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Отчёт анализа в машиночитаемом виде
+//
+// Те же показатели, что печатаются в консоль, но в структуре, которую можно
+// выгрузить в JSON для блокнотов и в Markdown для отчёта. Поля в битах:
+// энтропия и средняя длина — на символ алфавита (для биграмм — на биграмму).
+type analysisReport struct {
+	Source    string           `json:"source"`
+	Alphabets []alphabetReport `json:"alphabets"`
+}
+
+// показатели одного алфавита
+type alphabetReport struct {
+	Unit          string        `json:"unit"`  // char, bigram, byte — как флаг -unit
+	Title         string        `json:"title"` // подпись для людей
+	Symbols       int           `json:"symbols"`
+	Entropy       float64       `json:"entropy"`
+	UniformLength float64       `json:"uniform_length"` // ceil(log2 K)
+	Redundancy    float64       `json:"redundancy"`     // uniform_length - entropy
+	Coders        []coderReport `json:"coders"`
+}
+
+// показатели одного метода построения кода
+type coderReport struct {
+	ID            string  `json:"id"`
+	Name          string  `json:"name"`
+	AverageLength float64 `json:"average_length"`
+	Efficiency    float64 `json:"efficiency"` // entropy / average_length
+	Redundancy    float64 `json:"redundancy"` // average_length - entropy
+}
+
+func newAlphabetReport(unit, title string, alphabet []Symbol, methods []codeMethod) alphabetReport {
+	entropy := calculateEntropy(alphabet)
+	uniformLength := uniformCodeLength(len(alphabet))
+	report := alphabetReport{
+		Unit:          unit,
+		Title:         title,
+		Symbols:       len(alphabet),
+		Entropy:       entropy,
+		UniformLength: uniformLength,
+		Redundancy:    uniformLength - entropy,
+	}
+	for _, m := range methods {
+		avg := calculateAverageCodeLength(alphabet, m.Codes)
+		coder := coderReport{ID: m.ID, Name: m.Name, AverageLength: avg, Redundancy: avg - entropy}
+		if avg > 0 {
+			coder.Efficiency = entropy / avg
+		}
+		report.Coders = append(report.Coders, coder)
+	}
+	return report
+}
+
+func writeReportJSON(w io.Writer, report analysisReport) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+func writeReportMarkdown(w io.Writer, report analysisReport) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Анализ %s\n", report.Source)
+	for _, a := range report.Alphabets {
+		fmt.Fprintf(&b, "\n## %s (%s)\n\n", a.Title, a.Unit)
+		fmt.Fprintf(&b, "| Показатель | Значение |\n|---|---:|\n")
+		fmt.Fprintf(&b, "| Символов алфавита | %d |\n", a.Symbols)
+		fmt.Fprintf(&b, "| Энтропия, бит | %.4f |\n", a.Entropy)
+		fmt.Fprintf(&b, "| Длина равномерного кода, бит | %.0f |\n", a.UniformLength)
+		fmt.Fprintf(&b, "| Избыточность, бит | %.4f |\n", a.Redundancy)
+
+		fmt.Fprintf(&b, "\n| Метод | Средняя длина, бит | Эффективность | Избыточность, бит |\n|---|---:|---:|---:|\n")
+		for _, c := range a.Coders {
+			fmt.Fprintf(&b, "| %s | %.4f | %.4f | %.4f |\n", c.Name, c.AverageLength, c.Efficiency, c.Redundancy)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// записывает отчёт в оба формата
+func writeReportFiles(report analysisReport, jsonFile, markdownFile string) error {
+	for _, out := range []struct {
+		filename string
+		write    func(io.Writer, analysisReport) error
+	}{
+		{jsonFile, writeReportJSON},
+		{markdownFile, writeReportMarkdown},
+	} {
+		file, err := os.Create(out.filename)
+		if err != nil {
+			return err
+		}
+		if err := out.write(file, report); err != nil {
+			file.Close()
+			return err
+		}
+		if err := file.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestAlphabetReportSmallAlphabets(t *testing.T) {
+	for _, text := range []string{"", "a", "aaaa"} {
+		alphabet := makeAlphabet(text)
+		report := analysisReport{
+			Source:    "test",
+			Alphabets: []alphabetReport{newAlphabetReport("char", "Символы", alphabet, codeMethods(alphabet))},
+		}
+		if got := report.Alphabets[0].UniformLength; got != 0 {
+			t.Errorf("%q: длина равномерного кода %v, ожидался 0", text, got)
+		}
+		var b bytes.Buffer
+		if err := writeReportJSON(&b, report); err != nil {
+			t.Errorf("%q: %v", text, err)
+		}
+		if err := writeReportMarkdown(&b, report); err != nil {
+			t.Errorf("%q: %v", text, err)
+		}
+	}
+}
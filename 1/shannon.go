@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// Код Шеннона
+//
+// Символы упорядочиваются по убыванию вероятности, F_i — сумма вероятностей
+// предыдущих символов. Код символа — первые ceil(-log2 p_i) бит двоичной записи F_i.
+// Вычисления ведутся в целых числах по счётчикам, чтобы округление не нарушило префиксность.
+func generateShannonCodes(alphabet []Symbol) map[string]string {
+	sorted := append([]Symbol{}, alphabet...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Count > sorted[j].Count })
+
+	total := totalCount(sorted)
+	codes := make(map[string]string, len(sorted))
+	cum := uint64(0)
+	for _, s := range sorted {
+		length := max(selfInformationBits(uint64(s.Count), total), 1) // у единственного символа p = 1
+		codes[s.Char] = formatCode(binaryFraction(cum, total, length), length)
+		cum += uint64(s.Count)
+	}
+	return codes
+}
+
+// Код Шеннона-Фано-Элайеса
+//
+// Порядок символов не важен: берётся середина отрезка символа F_i + p_i/2,
+// и код — первые ceil(-log2 p_i) + 1 бит её двоичной записи. Лишний бит
+// гарантирует, что код не выходит за отрезок символа, а значит код префиксный.
+func generateShannonFanoEliasCodes(alphabet []Symbol) map[string]string {
+	total := totalCount(alphabet)
+	codes := make(map[string]string, len(alphabet))
+	cum := uint64(0)
+	for _, s := range alphabet {
+		length := selfInformationBits(uint64(s.Count), total) + 1
+		middle := 2*cum + uint64(s.Count) // середина отрезка в единицах 1/(2*total)
+		codes[s.Char] = formatCode(binaryFraction(middle, 2*total, length), length)
+		cum += uint64(s.Count)
+	}
+	return codes
+}
+
+func totalCount(alphabet []Symbol) uint64 {
+	total := uint64(0)
+	for _, s := range alphabet {
+		total += uint64(s.Count)
+	}
+	return total
+}
+
+// ceil(-log2(count/total)): наименьшее l, при котором count * 2^l >= total
+func selfInformationBits(count, total uint64) int {
+	length := 0
+	for count<<uint(length) < total {
+		length++
+	}
+	return length
+}
+
+// первые length бит двоичной записи дроби num/den (num < den)
+func binaryFraction(num, den uint64, length int) uint64 {
+	hi, lo := bits.Mul64(num, uint64(1)<<uint(length))
+	q, _ := bits.Div64(hi, lo, den)
+	return q
+}
+
+// метод построения кода и полученная таблица
+type codeMethod struct {
+	ID    string // имя латиницей для отчётов и командной строки
+	Name  string
+	Codes map[string]string
+}
+
+// все методы построения кода для одного алфавита
+func codeMethods(alphabet []Symbol) []codeMethod {
+	return []codeMethod{
+		{"shannon", "Шеннон", generateShannonCodes(alphabet)},
+		{"shannon-fano", "Шеннон-Фано", generateShannonFanoCodes(alphabet)},
+		{"shannon-fano-elias", "Шеннон-Фано-Элайес", generateShannonFanoEliasCodes(alphabet)},
+		{"huffman", "Хаффман", generateCanonicalHuffmanCodes(alphabet)},
+	}
+}
+
+// записывает по каждому символу вероятность, собственную информацию и коды всех методов
+func writeCodeComparisonCSV(alphabet []Symbol, methods []codeMethod, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	header := []string{"Символ", "Вероятность", "Собственная информация"}
+	for _, m := range methods {
+		header = append(header, m.Name, m.Name+" (длина)")
+	}
+	writer.Write(header)
+
+	for _, s := range alphabet {
+		row := []string{
+			escapeSpecialChars(s.Char),
+			strconv.FormatFloat(s.Prob, 'f', 6, 64),
+			strconv.FormatFloat(-math.Log2(s.Prob), 'f', 4, 64),
+		}
+		for _, m := range methods {
+			code := m.Codes[s.Char]
+			row = append(row, code, strconv.Itoa(len(code)))
+		}
+		writer.Write(row)
+	}
+	writer.Flush()
+
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// средняя длина и эффективность каждого метода
+func printCodeComparison(w io.Writer, title string, alphabet []Symbol, methods []codeMethod) {
+	entropy := calculateEntropy(alphabet)
+	fmt.Fprintf(w, "%s (энтропия %.4f бит):\n", title, entropy)
+	fmt.Fprintf(w, "  %-22s %14s %14s\n", "Метод", "Средняя длина", "Эффективность")
+	for _, m := range methods {
+		avg := calculateAverageCodeLength(alphabet, m.Codes)
+		fmt.Fprintf(w, "  %-22s %14.4f %14.4f\n", m.Name, avg, entropy/avg)
+	}
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// Потоковая обработка больших файлов
+//
+// Текст не читается в память целиком: частоты считаются за один проход по io.Reader,
+// кодирование — за второй. Память ограничена буферами bufio и таблицей кодов.
+
+// читает из потока очередной символ алфавита
+type symbolReader func(r *bufio.Reader) (string, error)
+
+// символ — знак UTF-8
+//
+// bufio.Reader.ReadRune сам дочитывает буфер, если многобайтовый символ UTF-8
+// оказался разрезан границей блока, поэтому символы на стыках не теряются.
+// Недопустимый байт становится отдельным символом из одного байта, а не U+FFFD,
+// поэтому файл восстанавливается байт в байт.
+func readRuneSymbol(r *bufio.Reader) (string, error) {
+	ch, size, err := r.ReadRune()
+	if err != nil {
+		return "", err
+	}
+	if ch == utf8.RuneError && size == 1 {
+		r.UnreadRune()
+		b, _ := r.ReadByte()
+		return byteSymbols[b], nil
+	}
+	return string(ch), nil
+}
+
+// Разбиение текста на символы
+//
+// Символ — знак UTF-8 или один недопустимый байт, как в readRuneSymbol. Все
+// алгоритмы, работающие с текстом в памяти, разбивают его только этими функциями,
+// поэтому таблицы, построенные по файлу и по строке, совпадают.
+
+// символ текста, начинающийся с байта i
+func textSymbolAt(text string, i int) string {
+	_, size := utf8.DecodeRuneInString(text[i:])
+	return text[i : i+size]
+}
+
+func textSymbols(text string) []string {
+	symbols := make([]string, 0, len(text))
+	for i := 0; i < len(text); {
+		symbol := textSymbolAt(text, i)
+		symbols = append(symbols, symbol)
+		i += len(symbol)
+	}
+	return symbols
+}
+
+// Алгоритмам, которым нужны руны (BWT, LZ77, LZW), недопустимый байт b передаётся
+// суррогатом invalidByteRune+b: декодер UTF-8 суррогатов не возвращает, поэтому
+// руна однозначно переводится обратно в символ.
+const invalidByteRune = 0xDC00
+
+func symbolRune(symbol string) rune {
+	r, size := utf8.DecodeRuneInString(symbol)
+	if r == utf8.RuneError && size == 1 {
+		return invalidByteRune + rune(symbol[0])
+	}
+	return r
+}
+
+func runeSymbol(r rune) string {
+	if r >= invalidByteRune+0x80 && r <= invalidByteRune+0xFF {
+		return byteSymbols[r-invalidByteRune]
+	}
+	return string(r)
+}
+
+func textRunes(text string) []rune {
+	runes := make([]rune, 0, len(text))
+	for i := 0; i < len(text); {
+		symbol := textSymbolAt(text, i)
+		runes = append(runes, symbolRune(symbol))
+		i += len(symbol)
+	}
+	return runes
+}
+
+func runesText(runes []rune) string {
+	var b strings.Builder
+	for _, r := range runes {
+		b.WriteString(runeSymbol(r))
+	}
+	return b.String()
+}
+
+// символ — один байт, алфавит из 256 значений; годится для любых файлов
+func readByteSymbol(r *bufio.Reader) (string, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	return byteSymbols[b], nil
+}
+
+// символ — пара соседних знаков UTF-8; пары не перекрываются, непарный последний
+// знак становится отдельным символом, так что декодер просто склеивает символы
+func readBigramSymbol(r *bufio.Reader) (string, error) {
+	first, err := readRuneSymbol(r)
+	if err != nil {
+		return "", err
+	}
+	second, err := readRuneSymbol(r)
+	if err == io.EOF {
+		return first, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return first + second, nil
+}
+
+// заранее построенные строки из одного байта, чтобы не выделять память на каждый байт
+var byteSymbols = func() [256]string {
+	var symbols [256]string
+	for i := range symbols {
+		symbols[i] = string([]byte{byte(i)})
+	}
+	return symbols
+}()
+
+// считает частоты символов потока
+func countFrequenciesStream(r io.Reader, read symbolReader) ([]Symbol, error) {
+	br := bufio.NewReader(r)
+	counts := make(map[string]int)
+	for {
+		symbol, err := read(br)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		counts[symbol]++
+	}
+	return alphabetFromCounts(counts), nil
+}
+
+// кодирует поток в формат контейнера
+//
+// Точное число бит известно заранее из частот alphabet, поэтому заголовок
+// пишется до данных и весь закодированный поток в памяти не держится.
+func encodeStream(r io.Reader, w io.Writer, alphabet []Symbol, codes map[string]string, read symbolReader) error {
+	var bitCount uint64
+	for _, s := range alphabet {
+		bitCount += uint64(s.Count) * uint64(len(codes[s.Char]))
+	}
+
+	out := bufio.NewWriter(w)
+	if err := writeContainerHeader(out, codes, bitCount); err != nil {
+		return err
+	}
+
+	if err := writeSymbols(r, out, codes, read, bitCount); err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+// кодирует символы потока после заголовка и сверяет число бит с записанным в заголовке
+func writeSymbols(r io.Reader, out *bufio.Writer, codes map[string]string, read symbolReader, bitCount uint64) error {
+	in := bufio.NewReader(r)
+	bw := newBitWriter(out)
+	for {
+		symbol, err := read(in)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := writeSymbolWithEscape(bw, symbol, codes); err != nil {
+			return err
+		}
+	}
+	if bw.count != bitCount {
+		return fmt.Errorf("частоты не соответствуют потоку: ожидалось %d бит, записано %d", bitCount, bw.count)
+	}
+	return bw.flush()
+}
+
+// декодирует контейнер из потока, выводя символы по мере декодирования
+func decodeStream(r io.Reader, w io.Writer) error {
+	in := bufio.NewReader(r)
+	codes, bitCount, err := readContainerHeader(in)
+	if err != nil {
+		return err
+	}
+	root, err := buildDecodeTree(codes)
+	if err != nil {
+		return err
+	}
+
+	out := bufio.NewWriter(w)
+	if err := readEncodedText(newBitReader(in), root, bitCount, out); err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+// кодирует файл потоково: первый проход по файлу — частоты, второй — кодирование
+//
+// С readByteSymbol это универсальный сжиматель: декодирование через decodeFile
+// восстанавливает файл байт в байт, потому что символы — сами байты.
+func encodeFile(input, output string, codes func([]Symbol) map[string]string, read symbolReader) ([]Symbol, error) {
+	in, err := os.Open(input)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	alphabet, err := countFrequenciesStream(in, read)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := in.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	out, err := os.Create(output)
+	if err != nil {
+		return nil, err
+	}
+	defer out.Close()
+
+	if err := encodeStream(in, out, alphabet, codes(alphabet), read); err != nil {
+		return nil, err
+	}
+	return alphabet, out.Close()
+}
+
+func decodeFile(input, output string) error {
+	in, err := os.Open(input)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if err := decodeStream(in, out); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// сжимает файл побайтовым кодом, восстанавливает и сверяет с исходным;
+// промежуточные файлы пишутся во временный каталог и удаляются
+func compressBinaryFile(filename string, codes func([]Symbol) map[string]string) (float64, error) {
+	scratch, err := os.MkdirTemp("", "lab1-binary")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(scratch)
+	compressed := filepath.Join(scratch, "compressed.bin")
+	restored := filepath.Join(scratch, "restored")
+
+	if _, err := encodeFile(filename, compressed, codes, readByteSymbol); err != nil {
+		return 0, err
+	}
+	if err := decodeFile(compressed, restored); err != nil {
+		return 0, err
+	}
+
+	original, err := os.ReadFile(filename)
+	if err != nil {
+		return 0, err
+	}
+	decoded, err := os.ReadFile(restored)
+	if err != nil {
+		return 0, err
+	}
+	if !bytes.Equal(original, decoded) {
+		return 0, fmt.Errorf("%s восстановлен с искажениями", filename)
+	}
+	return float64(len(original)) / float64(fileSize(compressed)), nil
+}
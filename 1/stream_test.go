@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTextSymbols(t *testing.T) {
+	for name, input := range roundTripInputs() {
+		symbols := textSymbols(input)
+		if got := strings.Join(symbols, ""); got != input {
+			t.Errorf("%s: символы склеились в %q", name, got)
+		}
+		if len(symbols) != utf8.RuneCountInString(input) {
+			t.Errorf("%s: %d символов, а рун %d", name, len(symbols), utf8.RuneCountInString(input))
+		}
+		// то же разбиение, что и при потоковом чтении файла
+		r := bufio.NewReader(strings.NewReader(input))
+		for i, want := range symbols {
+			got, err := readRuneSymbol(r)
+			if err != nil || got != want {
+				t.Errorf("%s: символ %d прочитан как %q (%v), ожидалось %q", name, i, got, err, want)
+				break
+			}
+		}
+		if _, err := readRuneSymbol(r); err != io.EOF {
+			t.Errorf("%s: после последнего символа %v вместо io.EOF", name, err)
+		}
+		alphabet, err := countFrequenciesStream(strings.NewReader(input), readRuneSymbol)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(alphabet) != len(makeAlphabet(input)) {
+			t.Errorf("%s: алфавит файла из %d символов, строки — из %d", name, len(alphabet), len(makeAlphabet(input)))
+		}
+		if got := runesText(textRunes(input)); got != input {
+			t.Errorf("%s: руны склеились в %q", name, got)
+		}
+	}
+}
+
+// все кодеры, работающие с текстом в памяти, восстанавливают его байт в байт
+func TestTextCodersRoundTrip(t *testing.T) {
+	coders := map[string]func(text string) (string, error){
+		"арифметический": func(text string) (string, error) {
+			alphabet := makeAlphabet(text)
+			data, _, err := arithmeticEncode(text, alphabet)
+			if err != nil {
+				return "", err
+			}
+			return arithmeticDecode(data, alphabet, utf8.RuneCountInString(text))
+		},
+		"адаптивный Хаффман": func(text string) (string, error) {
+			data, _, err := adaptiveHuffmanEncode(text)
+			if err != nil {
+				return "", err
+			}
+			return adaptiveHuffmanDecode(data, utf8.RuneCountInString(text))
+		},
+		"биграммы": func(text string) (string, error) {
+			bigrams := makeBigramAlphabet(text)
+			bigramCodes := generateCanonicalHuffmanCodes(withEscapeSymbol(bigrams, escapeSymbol, countBigramFallbacks(text, bigrams)))
+			charCodes := generateCanonicalHuffmanCodes(makeAlphabet(text))
+			encoded, err := encodeBigramText(text, bigramCodes, charCodes)
+			if err != nil {
+				return "", err
+			}
+			return decodeBigramText(encoded, bigramCodes, charCodes)
+		},
+		"LZ77 + Хаффман": func(text string) (string, error) {
+			deflated, err := deflateEncode(text)
+			if err != nil {
+				return "", err
+			}
+			return deflateDecode(deflated)
+		},
+		"LZW": func(text string) (string, error) {
+			lzw, err := lzwEncode(text)
+			if err != nil {
+				return "", err
+			}
+			return lzwDecode(lzw)
+		},
+	}
+	for name, input := range roundTripInputs() {
+		for _, coder := range sortedKeys(coders) {
+			decoded, err := coders[coder](input)
+			if err != nil {
+				t.Errorf("%s, %s: %v", name, coder, err)
+				continue
+			}
+			if decoded != input {
+				t.Errorf("%s, %s: восстановлено %q, ожидалось %q", name, coder, decoded, input)
+			}
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"container/heap"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Коды Тунсталла: строки переменной длины → кодовые слова фиксированной длины
+//
+// Словарь — полное дерево разбора: вначале листья — все символы алфавита,
+// затем самый вероятный лист раскрывается во всех K детей, пока число листьев
+// не превысит 2^bits. Каждый лист получает bits-битовый номер. Поскольку у каждого
+// внутреннего узла есть все дети, любой текст разбирается жадно без остатка,
+// кроме, может быть, хвоста; хвост дополняется до листа, а декодер обрезает
+// результат по известному числу символов.
+type tunstallNode struct {
+	prob     float64
+	children []*tunstallNode // nil у листа
+	word     int             // номер кодового слова листа
+	text     string
+}
+
+type tunstallCode struct {
+	bits    int
+	index   map[string]int
+	symbols []string
+	probs   []float64
+	root    *tunstallNode
+	words   []string
+}
+
+// очередь листьев по убыванию вероятности
+type tunstallQueue []*tunstallNode
+
+func (q tunstallQueue) Len() int           { return len(q) }
+func (q tunstallQueue) Less(i, j int) bool { return q[i].prob > q[j].prob }
+func (q tunstallQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *tunstallQueue) Push(x any)        { *q = append(*q, x.(*tunstallNode)) }
+func (q *tunstallQueue) Pop() any {
+	old := *q
+	node := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return node
+}
+
+func newTunstallCode(alphabet []Symbol, bits int) (*tunstallCode, error) {
+	k := len(alphabet)
+	if k == 0 {
+		return nil, errors.New("код Тунсталла для пустого алфавита не строится")
+	}
+	if bits < 1 || bits >= 31 || 1<<uint(bits) < k {
+		return nil, fmt.Errorf("словарь Тунсталла на %d бит не вмещает %d символов", bits, k)
+	}
+	t := &tunstallCode{bits: bits, index: make(map[string]int, k)}
+	for i, s := range alphabet {
+		t.index[s.Char] = i
+		t.symbols = append(t.symbols, s.Char)
+		t.probs = append(t.probs, s.Prob)
+	}
+
+	t.root = &tunstallNode{prob: 1}
+	queue := &tunstallQueue{}
+	expand := func(node *tunstallNode) {
+		node.children = make([]*tunstallNode, k)
+		for i := range node.children {
+			child := &tunstallNode{prob: node.prob * t.probs[i], text: node.text + t.symbols[i]}
+			node.children[i] = child
+			heap.Push(queue, child)
+		}
+	}
+	expand(t.root)
+	// из одного символа дерево не растёт: каждое слово — сам этот символ
+	for leaves := k; k > 1 && leaves+k-1 <= 1<<uint(bits); leaves += k - 1 {
+		expand(heap.Pop(queue).(*tunstallNode))
+	}
+
+	var number func(node *tunstallNode)
+	number = func(node *tunstallNode) {
+		if node.children == nil {
+			node.word = len(t.words)
+			t.words = append(t.words, node.text)
+			return
+		}
+		for _, child := range node.children {
+			number(child)
+		}
+	}
+	number(t.root)
+	return t, nil
+}
+
+// возвращает упакованные кодовые слова и их количество
+func (t *tunstallCode) encode(text string) ([]byte, int, error) {
+	var buf bytes.Buffer
+	bw := newBitWriter(&buf)
+	words := 0
+	node := t.root
+	for _, symbol := range textSymbols(text) {
+		i, exists := t.index[symbol]
+		if !exists {
+			return nil, 0, fmt.Errorf("символа %q нет в алфавите", symbol)
+		}
+		node = node.children[i]
+		if node.children == nil {
+			bw.writeBits(uint64(node.word), t.bits)
+			words++
+			node = t.root
+		}
+	}
+	// незаконченный хвост дополняется первым символом до ближайшего листа
+	if node != t.root {
+		for node.children != nil {
+			node = node.children[0]
+		}
+		bw.writeBits(uint64(node.word), t.bits)
+		words++
+	}
+	if err := bw.flush(); err != nil {
+		return nil, 0, err
+	}
+	return buf.Bytes(), words, nil
+}
+
+// декодирует words кодовых слов и обрезает результат до count символов
+func (t *tunstallCode) decode(data []byte, words, count int) (string, error) {
+	br := newBitReader(bytes.NewReader(data))
+	var decoded strings.Builder
+	for i := 0; i < words; i++ {
+		word, err := br.readBits(t.bits)
+		if err != nil {
+			return "", err
+		}
+		if word >= uint64(len(t.words)) {
+			return "", fmt.Errorf("кодового слова %d нет в словаре", word)
+		}
+		decoded.WriteString(t.words[word])
+	}
+
+	result := decoded.String()
+	for i := range result {
+		if count == 0 {
+			return result[:i], nil
+		}
+		count--
+	}
+	return result, nil
+}
+
+// кодирует текст кодом Тунсталла, проверяет декодирование и возвращает бит на символ
+func tunstallBitsPerChar(text string, alphabet []Symbol, bits int) (float64, error) {
+	t, err := newTunstallCode(alphabet, bits)
+	if err != nil {
+		return 0, err
+	}
+	data, words, err := t.encode(text)
+	if err != nil {
+		return 0, err
+	}
+	textLength := utf8.RuneCountInString(text)
+	decoded, err := t.decode(data, words, textLength)
+	if err != nil {
+		return 0, err
+	}
+	if decoded != text {
+		return 0, fmt.Errorf("текст после кода Тунсталла на %d бит не совпадает с исходным", bits)
+	}
+	return float64(words*bits) / float64(textLength), nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import "testing"
+
+func TestTunstallRoundTrip(t *testing.T) {
+	for name, input := range roundTripInputs() {
+		if input == "" {
+			continue
+		}
+		for _, bits := range []int{8, 10, 12} {
+			alphabet := makeAlphabet(input)
+			if 1<<bits < len(alphabet) {
+				continue
+			}
+			// tunstallBitsPerChar сам проверяет, что текст восстановлен
+			if _, err := tunstallBitsPerChar(input, alphabet, bits); err != nil {
+				t.Errorf("%s, %d бит: %v", name, bits, err)
+			}
+		}
+	}
+}
+
+func TestTunstallSingleSymbol(t *testing.T) {
+	rate, err := tunstallBitsPerChar("aaaa", makeAlphabet("aaaa"), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rate != 4 {
+		t.Errorf("%.2f бит на символ, ожидалось 4: каждое слово — один символ", rate)
+	}
+}
+
+func TestNewTunstallCodeErrors(t *testing.T) {
+	if _, err := newTunstallCode(nil, 8); err == nil {
+		t.Error("пустой алфавит: ожидалась ошибка")
+	}
+	if _, err := newTunstallCode(makeAlphabet("abc"), 1); err == nil {
+		t.Error("3 символа в словаре на 1 бит: ожидалась ошибка")
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// результат проверки таблицы кодов
+type codeTableReport struct {
+	KraftSum   float64     // сумма 2^-l по всем кодам, для однозначно декодируемого кода <= 1
+	Prefixes   [][2]string // пары (символ, символ), где код первого — префикс кода второго
+	Duplicates [][2]string // пары символов с одинаковыми кодами
+	Missing    []string    // символы алфавита без кода
+	Invalid    []string    // символы с пустым кодом или кодом не из '0' и '1'
+}
+
+// Проверка таблицы кодов
+//
+// Считает сумму Крафта-Макмиллана, ищет коды, являющиеся префиксами других
+// кодов, и совпадающие коды. Если передан алфавит, ищет и символы без кода.
+func validateCodeTable(codes map[string]string, alphabet []Symbol) codeTableReport {
+	var report codeTableReport
+
+	symbols := make([]string, 0, len(codes))
+	for symbol, code := range codes {
+		if code == "" || !isBinaryCode(code) {
+			report.Invalid = append(report.Invalid, symbol)
+			continue
+		}
+		report.KraftSum += math.Pow(2, -float64(len(code)))
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(report.Invalid)
+
+	// в лексикографическом порядке все коды с данным префиксом идут сразу за ним
+	sort.Slice(symbols, func(i, j int) bool {
+		ci, cj := codes[symbols[i]], codes[symbols[j]]
+		if ci != cj {
+			return ci < cj
+		}
+		return symbols[i] < symbols[j]
+	})
+	for i, symbol := range symbols {
+		code := codes[symbol]
+		for j := i + 1; j < len(symbols) && strings.HasPrefix(codes[symbols[j]], code); j++ {
+			pair := [2]string{symbol, symbols[j]}
+			if codes[symbols[j]] == code {
+				report.Duplicates = append(report.Duplicates, pair)
+			} else {
+				report.Prefixes = append(report.Prefixes, pair)
+			}
+		}
+	}
+
+	for _, s := range alphabet {
+		if _, exists := codes[s.Char]; !exists {
+			report.Missing = append(report.Missing, s.Char)
+		}
+	}
+	sort.Strings(report.Missing)
+	return report
+}
+
+func isBinaryCode(code string) bool {
+	for i := 0; i < len(code); i++ {
+		if code[i] != '0' && code[i] != '1' {
+			return false
+		}
+	}
+	return true
+}
+
+// таблица годится для кодирования: префиксная, без повторов и пропусков
+func (r codeTableReport) ok() bool {
+	return r.KraftSum <= 1+1e-9 && len(r.Prefixes) == 0 && len(r.Duplicates) == 0 &&
+		len(r.Missing) == 0 && len(r.Invalid) == 0
+}
+
+func (r codeTableReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "сумма Крафта %.6f", r.KraftSum)
+	if r.KraftSum > 1+1e-9 {
+		b.WriteString(" > 1")
+	}
+	for _, p := range r.Prefixes {
+		fmt.Fprintf(&b, "; код %q — префикс кода %q", escapeSpecialChars(p[0]), escapeSpecialChars(p[1]))
+	}
+	for _, p := range r.Duplicates {
+		fmt.Fprintf(&b, "; у %q и %q одинаковый код", escapeSpecialChars(p[0]), escapeSpecialChars(p[1]))
+	}
+	for _, s := range r.Missing {
+		fmt.Fprintf(&b, "; нет кода у %q", escapeSpecialChars(s))
+	}
+	for _, s := range r.Invalid {
+		fmt.Fprintf(&b, "; недопустимый код у %q", escapeSpecialChars(s))
+	}
+	return b.String()
+}
+
+// проверяет таблицу перед записью и печатает результат; некорректная таблица — ошибка
+func checkCodeTable(w io.Writer, name string, codes map[string]string, alphabet []Symbol) error {
+	report := validateCodeTable(codes, alphabet)
+	if !report.ok() {
+		return fmt.Errorf("таблица %s некорректна: %s", name, report)
+	}
+	fmt.Fprintf(w, "Проверка %s: %s ✓\n", name, report)
+	return nil
+}